@@ -0,0 +1,165 @@
+package go_vector_logger
+
+import "fmt"
+
+// defaultBufferSize is the async queue capacity used when Options.BufferSize is not set.
+const defaultBufferSize = 1000
+
+// startAsyncWorker creates the async queue and starts the goroutine draining it.
+func (l *VectorLogger) startAsyncWorker() {
+	size := l.Options.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	l.queue = make(chan *Message, size)
+
+	l.worker.Add(1)
+	go l.processQueue(l.queue)
+}
+
+// processQueue delivers queued messages until the queue is closed and drained.
+func (l *VectorLogger) processQueue(queue <-chan *Message) {
+	defer l.worker.Done()
+
+	for msg := range queue {
+		l.draining.Store(true)
+		l.processQueued(msg)
+		l.draining.Store(false)
+	}
+}
+
+// processQueued delivers a message taken from the queue, or releases the
+// caller of drainQueue waiting on a marker.
+func (l *VectorLogger) processQueued(msg *Message) {
+	if msg.flushed != nil {
+		close(msg.flushed)
+		return
+	}
+	l.releaseMemory(msg.size)
+	l.deliver(msg)
+}
+
+// onWorker reports whether the caller may be the async worker, running
+// Options.OnError or Options.FailureHandler for the message it delivers: it
+// must then neither wait for the worker nor for room in the queue. Other
+// goroutines calling meanwhile are treated alike and do the work inline.
+func (l *VectorLogger) onWorker() bool {
+	return l.draining.Load() && l.callbacks.Load() > 0
+}
+
+// drainQueue blocks until every message queued before the call has been
+// delivered by the async worker. It returns false when there is no queue.
+// Called by the worker itself, it delivers them inline instead, since the
+// worker cannot wait for a marker only it would take from the queue.
+func (l *VectorLogger) drainQueue() bool {
+	l.queueMu.RLock()
+	if l.queue == nil {
+		l.queueMu.RUnlock()
+		return false
+	}
+	if l.onWorker() {
+		queue := l.queue
+		l.queueMu.RUnlock()
+		for {
+			select {
+			case msg, ok := <-queue:
+				if !ok {
+					return true
+				}
+				l.processQueued(msg)
+			default:
+				return true
+			}
+		}
+	}
+	marker := &Message{flushed: make(chan struct{})}
+	l.queue <- marker
+	l.queueMu.RUnlock()
+
+	<-marker.flushed
+	return true
+}
+
+// enqueue hands msg to the async worker. It returns false when the logger is
+// not in async mode (or already closed) and the caller must deliver msg itself.
+// When the queue is full, msg is dropped unless Options.BlockOnFull is set or
+// msg is FATAL, which waits for room, or is delivered by the caller when that
+// may be the worker itself; it is dropped as well when it does not fit in
+// Options.MaxMemoryBytes.
+func (l *VectorLogger) enqueue(msg *Message) bool {
+	if l.core == nil || !l.Options.Async {
+		return false
+	}
+
+	// The budget is reserved before taking queueMu, since it may take l.mu.
+	msg.size = messageSize(msg)
+	if !l.reserveQueueMemory(msg.size, msg.level) {
+		l.stats.dropped.Add(1)
+		l.reportError(fmt.Errorf("memory budget of %d bytes exceeded, dropping message: %s", l.Options.MaxMemoryBytes, msg.Message))
+		return true
+	}
+	l.queueMu.RLock()
+	if l.queue == nil {
+		l.queueMu.RUnlock()
+		l.releaseMemory(msg.size)
+		return false
+	}
+
+	// FATAL messages are never dropped: the process exits right after them.
+	if l.Options.BlockOnFull || msg.level == FATAL {
+		if l.onWorker() {
+			// The worker would wait for room only it can make: the
+			// caller delivers msg itself when the queue is full, e.g.
+			// Fatal called from OnError writes inline, then exits.
+			select {
+			case l.queue <- msg:
+				l.queueMu.RUnlock()
+				return true
+			default:
+				l.queueMu.RUnlock()
+				l.releaseMemory(msg.size)
+				return false
+			}
+		}
+		l.queue <- msg
+		l.queueMu.RUnlock()
+		return true
+	}
+	select {
+	case l.queue <- msg:
+		l.queueMu.RUnlock()
+	default:
+		l.queueMu.RUnlock()
+		l.releaseMemory(msg.size)
+		// Reported without queueMu, which OnError logging again would
+		// take recursively, deadlocking with a pending Close.
+		l.stats.dropped.Add(1)
+		l.reportError(fmt.Errorf("log queue is full, dropping message: %s", msg.Message))
+	}
+	return true
+}
+
+// QueueLen returns the number of messages waiting in the async queue, 0 when
+// the logger is not in async mode or is closed. Together with QueueCap it
+// shows whether the application logs faster than the network can carry;
+// messages logged while the queue is full are dropped and counted in
+// Stats.Dropped, unless Options.BlockOnFull is set.
+func (l *VectorLogger) QueueLen() int {
+	if l.core == nil {
+		return 0
+	}
+	l.queueMu.RLock()
+	defer l.queueMu.RUnlock()
+	return len(l.queue)
+}
+
+// QueueCap returns the capacity of the async queue, see Options.BufferSize,
+// 0 when the logger is not in async mode or is closed.
+func (l *VectorLogger) QueueCap() int {
+	if l.core == nil {
+		return 0
+	}
+	l.queueMu.RLock()
+	defer l.queueMu.RUnlock()
+	return cap(l.queue)
+}
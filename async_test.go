@@ -0,0 +1,354 @@
+package go_vector_logger
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedWriter blocks every write until open is closed.
+type gatedWriter struct {
+	open chan struct{}
+	mu   sync.Mutex
+	buf  strings.Builder
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.open
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestFatalIsNotDroppedOnFullQueue(t *testing.T) {
+	out := &gatedWriter{open: make(chan struct{})}
+	internal := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:              out,
+		Async:               true,
+		BufferSize:          1,
+		NoExit:              true,
+		InternalErrorWriter: internal,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// The worker blocks on the first message and the second fills the queue.
+	logger.Info("first")
+	for logger.QueueLen() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	logger.Info("second")
+	logger.Info("dropped")
+	if got := logger.Stats().Dropped; got != 1 {
+		t.Fatalf("Dropped = %d, want 1", got)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Fatal("fatal")
+	}()
+	select {
+	case <-done:
+		t.Fatal("Fatal returned before the queue had room")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(out.open)
+	<-done
+
+	if !strings.Contains(out.String(), `"fatal"`) {
+		t.Errorf("fatal message not delivered, got:\n%s", out.String())
+	}
+	if got := logger.Stats().Dropped; got != 1 {
+		t.Errorf("Dropped = %d, want 1", got)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestCloseWithTimeoutReturnsDuringSlowDrain(t *testing.T) {
+	out := &gatedWriter{open: make(chan struct{})}
+	defer close(out.open)
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, Async: true, BufferSize: 100})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		logger.Infof("stuck %d", i)
+	}
+
+	const timeout = 100 * time.Millisecond
+	start := time.Now()
+	err = logger.CloseWithTimeout(timeout)
+	if elapsed := time.Since(start); elapsed > timeout+time.Second {
+		t.Errorf("CloseWithTimeout returned after %v, timeout %v", elapsed, timeout)
+	}
+	if err == nil {
+		t.Error("CloseWithTimeout reported a complete drain with the writer blocked")
+	}
+}
+
+func TestQueueDepthAndDrops(t *testing.T) {
+	out := &gatedWriter{open: make(chan struct{})}
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:              out,
+		Async:               true,
+		BufferSize:          5,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := logger.QueueCap(); got != 5 {
+		t.Errorf("QueueCap = %d, want 5", got)
+	}
+
+	// The worker blocks on the first message, then the queue fills up.
+	logger.Info("blocking")
+	for logger.QueueLen() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i <= 5; i++ {
+		logger.Infof("queued %d", i)
+		if got := logger.QueueLen(); got != i {
+			t.Errorf("QueueLen = %d after %d queued messages", got, i)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		logger.Infof("overflow %d", i)
+	}
+	if got := logger.Stats().Dropped; got != 3 {
+		t.Errorf("Dropped = %d, want 3", got)
+	}
+
+	close(out.open)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 6 {
+		t.Errorf("delivered %d messages, want 6", got)
+	}
+	if got := logger.QueueCap(); got != 0 {
+		t.Errorf("QueueCap = %d after Close, want 0", got)
+	}
+}
+
+func TestAsyncCloseDrainsQueue(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{Async: true, BufferSize: 100})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		logger.Infof("message %d", i)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if lines := server.waitForLines(t, 50); len(lines) != 50 {
+		t.Errorf("received %d lines after Close, want 50", len(lines))
+	}
+}
+
+func TestBlockOnFullWaitsForRoom(t *testing.T) {
+	out := &gatedWriter{open: make(chan struct{})}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, Async: true, BufferSize: 1, BlockOnFull: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// The worker blocks on the first message and the second fills the queue.
+	logger.Info("first")
+	for logger.QueueLen() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	logger.Info("second")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Info("third")
+	}()
+	select {
+	case <-done:
+		t.Fatal("Info returned with the queue full and BlockOnFull set")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(out.open)
+	<-done
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := logger.Stats().Dropped; got != 0 {
+		t.Errorf("Dropped = %d, want 0", got)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 3 {
+		t.Errorf("delivered %d messages, want 3", got)
+	}
+}
+
+func TestFlushFromOnErrorInAsyncMode(t *testing.T) {
+	out := &syncBuffer{}
+	flushed := make(chan error, 1)
+	var once sync.Once
+	var logger *VectorLogger
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:              out,
+		ExtraWriters:        []io.Writer{failingWriter{}},
+		Async:               true,
+		InternalErrorWriter: &syncBuffer{},
+		OnError: func(error) {
+			// Called by the worker, which delivers the queue itself.
+			once.Do(func() {
+				logger.Info("logged from OnError")
+				flushed <- logger.Flush()
+			})
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("first")
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush called from OnError did not return")
+	}
+	if !strings.Contains(out.String(), "logged from OnError") {
+		t.Errorf("message logged from OnError not delivered by Flush, got:\n%s", out.String())
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestLoggingFromOnErrorOnFullQueue(t *testing.T) {
+	logged := make(chan struct{})
+	var once sync.Once
+	var logger *VectorLogger
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:              failingWriter{},
+		Async:               true,
+		BlockOnFull:         true,
+		BufferSize:          2,
+		InternalErrorWriter: &syncBuffer{},
+		OnError: func(error) {
+			// More messages than the queue holds, logged by the worker.
+			once.Do(func() {
+				for i := 0; i < 5; i++ {
+					logger.Info("logged from OnError")
+				}
+				close(logged)
+			})
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("first")
+
+	select {
+	case <-logged:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the worker blocked on its own queue, %d messages queued", logger.QueueLen())
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestFatalFromOnErrorOnFullQueue(t *testing.T) {
+	out := &syncBuffer{}
+	done := make(chan struct{})
+	var once sync.Once
+	var logger *VectorLogger
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:              out,
+		ExtraWriters:        []io.Writer{failingWriter{}},
+		Async:               true,
+		BufferSize:          2,
+		NoExit:              true,
+		InternalErrorWriter: &syncBuffer{},
+		OnError: func(error) {
+			once.Do(func() {
+				defer close(done)
+				for i := 0; i < 3; i++ {
+					logger.Info("filling the queue")
+				}
+				logger.Fatal("fatal")
+			})
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("first")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fatal called from OnError did not return")
+	}
+	if !strings.Contains(out.String(), `"fatal"`) {
+		t.Errorf("fatal message not delivered, got:\n%s", out.String())
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestLoggingFromOnErrorDoesNotLoopInAsyncMode(t *testing.T) {
+	internal := &syncBuffer{}
+	var calls atomic.Int64
+	var logger *VectorLogger
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:              failingWriter{},
+		Async:               true,
+		InternalErrorWriter: internal,
+		OnError: func(err error) {
+			calls.Add(1)
+			logger.Warnf("delivery failed: %v", err)
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("first")
+	if err := logger.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("OnError called %d times, want once for the first message", got)
+	}
+	if !strings.Contains(internal.String(), "cannot send data to vector") {
+		t.Errorf("internal errors = %q, want the failure of the message logged by OnError", internal.String())
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package go_vector_logger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultMaxBackoff caps the reconnect backoff when Options.MaxBackoff is not set.
+const defaultMaxBackoff = time.Minute
+
+// ErrReconnectBackoff is returned for messages sent while the logger waits
+// before its next reconnect attempt.
+var ErrReconnectBackoff = errors.New("waiting before the next reconnect attempt")
+
+// BackoffState describes the reconnect backoff of a logger.
+type BackoffState struct {
+	Attempts    int           // Consecutive failed connection attempts.
+	Backoff     time.Duration // Wait applied after the last failed attempt.
+	NextAttempt time.Time     // Earliest time of the next connection attempt.
+}
+
+// BackoffState returns the current reconnect backoff state.
+func (l *VectorLogger) BackoffState() BackoffState {
+	if l.core == nil {
+		return BackoffState{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.backoff
+}
+
+// reconnect establishes a new connection, honoring the reconnect backoff when
+// Options.InitialBackoff is set. The caller must hold l.mu.
+func (l *VectorLogger) reconnect() error {
+	if l.Options.InitialBackoff <= 0 {
+		return l.establishConnection()
+	}
+
+	if l.reconnectsExhausted() {
+		return fmt.Errorf("cannot send logs to vector on: %s: giving up after %d reconnect attempts", l.address(), l.backoff.Attempts)
+	}
+	if time.Now().Before(l.backoff.NextAttempt) {
+		return fmt.Errorf("cannot send logs to vector on: %s: %w", l.address(), ErrReconnectBackoff)
+	}
+
+	if err := l.establishConnection(); err != nil {
+		l.backoff.Attempts++
+		l.backoff.Backoff = l.nextBackoff()
+		l.backoff.NextAttempt = time.Now().Add(l.backoff.Backoff)
+		return err
+	}
+	l.backoff = BackoffState{}
+	return nil
+}
+
+// reconnectsExhausted reports whether Options.MaxReconnectAttempts failed
+// attempts were made in a row, after which reconnect gives up. The caller
+// must hold l.mu.
+func (l *VectorLogger) reconnectsExhausted() bool {
+	limit := l.Options.MaxReconnectAttempts
+	return l.Options.InitialBackoff > 0 && limit > 0 && l.backoff.Attempts >= limit
+}
+
+// nextBackoff doubles the current backoff, starting at Options.InitialBackoff
+// and capped at Options.MaxBackoff.
+func (l *VectorLogger) nextBackoff() time.Duration {
+	maxBackoff := l.Options.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	next := l.Options.InitialBackoff
+	if l.backoff.Backoff > 0 {
+		next = l.backoff.Backoff * 2
+	}
+	if next > maxBackoff {
+		return maxBackoff
+	}
+	return next
+}
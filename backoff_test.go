@@ -0,0 +1,56 @@
+package go_vector_logger
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	port := closedPort(t)
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{
+		LazyConnect:         true,
+		InitialBackoff:      20 * time.Millisecond,
+		MaxBackoff:          50 * time.Millisecond,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	// waitForNextAttempt waits until the backoff allows another attempt.
+	waitForNextAttempt := func() {
+		time.Sleep(time.Until(logger.BackoffState().NextAttempt) + 5*time.Millisecond)
+	}
+	for i, want := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond} {
+		if err := logger.TryInfo("refused"); err == nil {
+			t.Fatal("TryInfo succeeded with Vector down")
+		}
+		state := logger.BackoffState()
+		if state.Attempts != i+1 || state.Backoff != want {
+			t.Errorf("after %d refused attempts: Attempts = %d, Backoff = %v, want %d and %v", i+1, state.Attempts, state.Backoff, i+1, want)
+		}
+		if err := logger.TryInfo("waiting"); !errors.Is(err, ErrReconnectBackoff) {
+			t.Errorf("TryInfo during the backoff = %v, want ErrReconnectBackoff", err)
+		}
+		if got := logger.BackoffState().Attempts; got != i+1 {
+			t.Errorf("a message during the backoff counted as an attempt: Attempts = %d", got)
+		}
+		waitForNextAttempt()
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.FormatInt(port, 10)))
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	if err := logger.TryInfo("accepted"); err != nil {
+		t.Fatalf("TryInfo with Vector up: %v", err)
+	}
+	if state := logger.BackoffState(); state != (BackoffState{}) {
+		t.Errorf("state after reconnecting = %+v, want it reset", state)
+	}
+}
@@ -0,0 +1,127 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 100         // Messages per batch when only Options.BatchInterval is set.
+	defaultBatchInterval = time.Second // Flush interval when only Options.BatchSize is set.
+)
+
+// failedMessage is a message whose batch could not be written, with the
+// error of the write.
+type failedMessage struct {
+	msg    *Message
+	err    error
+	logger *VectorLogger // Logger whose failure handling gets msg, the unlocking one when nil.
+}
+
+// batchRecord is a message of the batch, encoded in size bytes of it.
+type batchRecord struct {
+	msg  *Message
+	size int
+}
+
+// batching reports whether network writes are coalesced into batches.
+func (l *VectorLogger) batching() bool {
+	return l.Options.BatchSize > 1 || l.Options.BatchInterval > 0
+}
+
+// batchSize returns the number of messages that triggers a batch write.
+func (l *VectorLogger) batchSize() int {
+	if l.Options.BatchSize > 0 {
+		return l.Options.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// batchInterval returns the longest time a message waits in the batch.
+func (l *VectorLogger) batchInterval() time.Duration {
+	if l.Options.BatchInterval > 0 {
+		return l.Options.BatchInterval
+	}
+	return defaultBatchInterval
+}
+
+// startBatcher starts the goroutine writing out the batch periodically.
+func (l *VectorLogger) startBatcher() {
+	l.wg.Add(1)
+	go l.runBatcher(l.stopChan)
+}
+
+// runBatcher flushes the batch every batch interval until stop is closed.
+func (l *VectorLogger) runBatcher(stop <-chan struct{}) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.batchInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			_ = l.flushBatch()
+			l.unlock()
+		}
+	}
+}
+
+// addToBatch appends msg, encoded as data, to the batch and writes the batch
+// out once it is full, or right away for fatal messages. The messages of a
+// batch that cannot be written are handed to the failure handling by unlock,
+// msg included, so addToBatch never fails itself; msg is only dropped when
+// Options.MaxMemoryBytes leaves no room for it. The caller must hold l.mu.
+func (l *VectorLogger) addToBatch(data []byte, msg *Message) {
+	if !l.reserveMemory(len(data), msg.level) {
+		l.stats.dropped.Add(1)
+		l.deferError(fmt.Errorf("memory budget of %d bytes exceeded, dropping message: %s", l.Options.MaxMemoryBytes, msg.Message))
+		return
+	}
+	l.batch.Write(data)
+	l.batched = append(l.batched, batchRecord{msg: msg, size: len(data)})
+	if len(l.batched) >= l.batchSize() || msg.level == FATAL {
+		_ = l.flushBatch()
+	}
+}
+
+// flushBatch writes the pending batch to Vector in a single write. When the
+// write fails, every message of the batch is handed to the failure handling
+// by unlock, once l.mu is released, and the error is returned. The caller
+// must hold l.mu.
+func (l *VectorLogger) flushBatch() error {
+	if len(l.batched) == 0 {
+		return nil
+	}
+	// Detach the batch first: the write may shed buffered records to make
+	// room in the offline buffer.
+	records := l.batched
+	l.batched = nil
+	l.releaseMemory(l.batch.Len())
+	err := l.writeToConn(l.batch.Bytes(), len(records), batchLevel(records), time.Time{})
+	if err != nil {
+		for _, record := range records {
+			l.failures = append(l.failures, failedMessage{msg: record.msg, err: err})
+		}
+	}
+	l.batch.Reset()
+	for i := range records {
+		records[i] = batchRecord{}
+	}
+	l.batched = records[:0]
+	return err
+}
+
+// batchLevel returns the most severe level of records.
+func batchLevel(records []batchRecord) string {
+	level := TRACE
+	for _, record := range records {
+		if levelSeverity[record.msg.level] > levelSeverity[level] {
+			level = record.msg.level
+		}
+	}
+	return level
+}
@@ -0,0 +1,121 @@
+package go_vector_logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailedBatchHandlesEveryMessageOnce(t *testing.T) {
+	var mu sync.Mutex
+	handled := map[string]int{}
+	logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{
+		LazyConnect:   true,
+		BatchSize:     3,
+		BatchInterval: time.Hour,
+		FailureHandler: func(msg *Message, err error) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[msg.Message]++
+			return err
+		},
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	messages := []string{"one", "two", "three", "four", "five", "six", "seven"}
+	for _, message := range messages {
+		logger.Info(message)
+	}
+	if err := logger.Flush(); err == nil {
+		t.Error("Flush of the last batch succeeded with Vector down")
+	}
+
+	mu.Lock()
+	for _, message := range messages {
+		if handled[message] != 1 {
+			t.Errorf("%q handled %d times, want 1", message, handled[message])
+		}
+	}
+	mu.Unlock()
+	stats := logger.Stats()
+	if stats.Failed != uint64(len(messages)) || stats.Dropped != uint64(len(messages)) {
+		t.Errorf("Failed = %d, Dropped = %d, want %d each", stats.Failed, stats.Dropped, len(messages))
+	}
+	_ = logger.Close()
+}
+
+func TestFailedBatchRecoveredByFailureHandler(t *testing.T) {
+	logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{
+		LazyConnect:   true,
+		BatchSize:     2,
+		BatchInterval: time.Hour,
+		FailureHandler: func(msg *Message, err error) error {
+			return nil
+		},
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("one")
+	logger.Info("two")
+
+	stats := logger.Stats()
+	if stats.Failed != 2 || stats.Dropped != 0 {
+		t.Errorf("Failed = %d, Dropped = %d, want 2 and 0", stats.Failed, stats.Dropped)
+	}
+	_ = logger.Close()
+}
+
+// countingConn counts the writes made on a connection.
+type countingConn struct {
+	net.Conn
+	writes atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(p)
+}
+
+func TestBatchCoalescesWrites(t *testing.T) {
+	server := newTestServer(t)
+	raw, err := net.Dial("tcp", server.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	conn := &countingConn{Conn: raw}
+	logger, err := New("test", "INFO", "", 0, Options{Conn: conn, BatchSize: 10, BatchInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	const count = 95
+	for i := 0; i < count; i++ {
+		logger.Infof("message %d", i)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	_ = raw.Close()
+
+	lines := server.waitForLines(t, count)
+	if len(lines) != count {
+		t.Fatalf("received %d lines, want %d", len(lines), count)
+	}
+	for i, line := range lines {
+		var msg Message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Message != fmt.Sprintf("message %d", i) {
+			t.Errorf("line %d = %q (%v)", i, line, err)
+		}
+	}
+	if writes := conn.writes.Load(); writes != 10 {
+		t.Errorf("%d messages took %d writes, want 10", count, writes)
+	}
+}
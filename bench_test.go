@@ -0,0 +1,91 @@
+package go_vector_logger
+
+import (
+	"io"
+	"testing"
+)
+
+// newBenchLogger returns a logger at level writing JSON to io.Discard.
+func newBenchLogger(tb testing.TB, level string, options ...Options) *VectorLogger {
+	tb.Helper()
+	var opts Options
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	opts.Writer = io.Discard
+	logger, err := New("bench", level, "", 0, opts)
+	if err != nil {
+		tb.Fatalf("New: %v", err)
+	}
+	return logger
+}
+
+func TestDisabledLevelsDoNotAllocate(t *testing.T) {
+	logger := newBenchLogger(t, ERROR)
+	multi := NewMultiLogger(logger, newBenchLogger(t, WARN))
+	fields := map[string]interface{}{"key": "value"}
+	calls := map[string]func(){
+		"Tracef":      func() { logger.Tracef("trace %s %d", "value", 42) },
+		"Debugf":      func() { logger.Debugf("debug %s %d", "value", 42) },
+		"Infof":       func() { logger.Infof("info %s %d", "value", 42) },
+		"Warnf":       func() { logger.Warnf("warn %s %d", "value", 42) },
+		"Debug":       func() { logger.Debug("debug") },
+		"InfoFields":  func() { logger.InfoFields("info", fields) },
+		"InfoAs":      func() { logger.InfoAs("other", "info") },
+		"Multi Debug": func() { multi.Debugf("debug %s", "value") },
+	}
+	for name, call := range calls {
+		if allocs := testing.AllocsPerRun(100, call); allocs != 0 {
+			t.Errorf("%s at a disabled level: %v allocations, want 0", name, allocs)
+		}
+	}
+}
+
+func TestSeverityMatchesLevelSeverity(t *testing.T) {
+	for level, want := range levelSeverity {
+		if got := severity(level); got != want {
+			t.Errorf("severity(%s) = %d, want %d", level, got, want)
+		}
+	}
+}
+
+func BenchmarkDisabledDebugf(b *testing.B) {
+	logger := newBenchLogger(b, ERROR)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("debug %s %d", "value", 42)
+	}
+}
+
+func BenchmarkDisabledDebug(b *testing.B) {
+	logger := newBenchLogger(b, ERROR)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Debug("debug")
+	}
+}
+
+func BenchmarkDisabledInfoFields(b *testing.B) {
+	logger := newBenchLogger(b, ERROR)
+	fields := map[string]interface{}{"key": "value"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.InfoFields("info", fields)
+	}
+}
+
+func BenchmarkDisabledMultiLoggerDebugf(b *testing.B) {
+	multi := NewMultiLogger(newBenchLogger(b, ERROR), newBenchLogger(b, WARN))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		multi.Debugf("debug %s %d", "value", 42)
+	}
+}
+
+func BenchmarkEnabledInfof(b *testing.B) {
+	logger := newBenchLogger(b, INFO)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Infof("info %s %d", "value", 42)
+	}
+}
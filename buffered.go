@@ -0,0 +1,144 @@
+package go_vector_logger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// defaultFlushInterval is how often buffered writes are flushed when
+	// Options.FlushInterval is not set.
+	defaultFlushInterval = time.Second
+	// writeBufferSize is the size of the buffer of Options.BufferedWrites.
+	writeBufferSize = 4096
+)
+
+// validateBufferedWrites checks Options.BufferedWrites against the protocol.
+func validateBufferedWrites(opts *Options) error {
+	if !opts.BufferedWrites {
+		return nil
+	}
+	switch opts.Protocol {
+	case "udp", "http":
+		return fmt.Errorf("BufferedWrites is not supported with the %s protocol", opts.Protocol)
+	}
+	return nil
+}
+
+// flushInterval returns the longest time written data stays buffered.
+func (l *VectorLogger) flushInterval() time.Duration {
+	if l.Options.FlushInterval > 0 {
+		return l.Options.FlushInterval
+	}
+	return defaultFlushInterval
+}
+
+// connWriter returns the writer of the current connection: the connection
+// itself, or with Options.BufferedWrites a buffer in front of it, which is
+// started on the first write unless it does not fit in Options.MaxMemoryBytes.
+// Nothing is shed to make room for it, since the writes may come from the
+// offline buffer. The caller must hold l.mu and l.conn must be set.
+func (l *VectorLogger) connWriter() io.Writer {
+	if !l.Options.BufferedWrites {
+		return l.conn
+	}
+	if l.bufw == nil {
+		l.memory.Add(writeBufferSize)
+		if l.overBudget() {
+			l.memory.Add(-writeBufferSize)
+			return l.conn
+		}
+		l.bufw = bufio.NewWriterSize(l.conn, writeBufferSize)
+	}
+	return l.bufw
+}
+
+// releaseWriteBuffer discards the write buffer of the current connection, if
+// any. The caller must hold l.mu.
+func (l *VectorLogger) releaseWriteBuffer() {
+	if l.bufw != nil {
+		l.releaseMemory(l.bufw.Size())
+		l.bufw = nil
+	}
+}
+
+// countWritten counts messages written to the connection as sent, or, while
+// they may still sit in the write buffer, once it is flushed. The caller must
+// hold l.mu.
+func (l *VectorLogger) countWritten(messages int) {
+	if l.bufw != nil {
+		l.unflushed += messages
+		return
+	}
+	l.stats.sent.Add(uint64(messages))
+}
+
+// flushBuffered writes out the write buffer of the current connection, if
+// any, bounded by Options.WriteTimeout. The messages it held are counted as
+// sent, or as dropped when the write fails: the buffer is then discarded and
+// the caller must close the connection. The caller must hold l.mu.
+func (l *VectorLogger) flushBuffered() error {
+	if l.bufw == nil {
+		return nil
+	}
+	if timeout := l.Options.WriteTimeout; timeout > 0 && l.bufw.Buffered() > 0 {
+		if err := l.conn.SetWriteDeadline(time.Now().Add(timeout)); err == nil {
+			defer func() { _ = l.conn.SetWriteDeadline(time.Time{}) }()
+		}
+	}
+
+	err := l.bufw.Flush()
+	messages := l.unflushed
+	l.unflushed = 0
+	if err != nil {
+		l.releaseWriteBuffer()
+		l.stats.failed.Add(uint64(messages))
+		l.stats.dropped.Add(uint64(messages))
+		err = fmt.Errorf("cannot send %d buffered messages to vector: %w", messages, err)
+		l.setLastError(err)
+		return err
+	}
+	l.stats.sent.Add(uint64(messages))
+	return nil
+}
+
+// flushFatal writes out the write buffer right away, so that a fatal message
+// reaches Vector before the process exits. The caller must hold l.mu.
+func (l *VectorLogger) flushFatal() error {
+	if err := l.flushBuffered(); err != nil {
+		l.loseConnection()
+		return err
+	}
+	return nil
+}
+
+// startFlusher starts the goroutine flushing the write buffer periodically.
+func (l *VectorLogger) startFlusher() {
+	l.wg.Add(1)
+	go l.runFlusher(l.stopChan)
+}
+
+// runFlusher flushes the write buffer every flush interval until stop is
+// closed.
+func (l *VectorLogger) runFlusher(stop <-chan struct{}) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.flushInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if err := l.flushBuffered(); err != nil {
+				l.loseConnection()
+				l.deferError(err)
+			}
+			l.unlock()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package go_vector_logger
+
+import (
+	"runtime"
+	"sync"
+)
+
+// callerSkip is the number of frames between runtime.Callers in newMessage
+// and the application code that called a public logging method (through
+// sendMessage or trySendMessage).
+const callerSkip = 4
+
+// caller is the resolved source location of a log call.
+type caller struct {
+	File     string
+	Line     int
+	Function string
+}
+
+// callerCache caches resolved call sites by program counter. Call sites are
+// few and repeat constantly, so after warm-up only the cheap runtime.Callers
+// remains on the hot path.
+var callerCache sync.Map // map[uintptr]caller
+
+// captureCaller returns the source location skip frames above its caller.
+func captureCaller(skip int) (caller, bool) {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+1, pcs[:]) == 0 {
+		return caller{}, false
+	}
+	if cached, ok := callerCache.Load(pcs[0]); ok {
+		return cached.(caller), true
+	}
+
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	resolved := caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+	callerCache.Store(pcs[0], resolved)
+	return resolved, true
+}
@@ -0,0 +1,80 @@
+package go_vector_logger
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestIncludeCallerReportsCallSite(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, IncludeCaller: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var lines []int
+	for i := 0; i < 2; i++ {
+		_, _, line, _ := runtime.Caller(0)
+		logger.Infof("message %d", i)
+		lines = append(lines, line+1)
+	}
+	_, file, line, _ := runtime.Caller(0)
+	logger.Warn("other call site")
+	lines = append(lines, line+1)
+	_, _, line, _ = runtime.Caller(0)
+	logger.Info("without format")
+	lines = append(lines, line+1)
+
+	decoder := json.NewDecoder(strings.NewReader(out.String()))
+	for i, want := range lines {
+		var msg Message
+		if err := decoder.Decode(&msg); err != nil {
+			t.Fatalf("decoding message %d: %v", i, err)
+		}
+		if msg.File != file || msg.Line != want {
+			t.Errorf("message %d reported %s:%d, want %s:%d", i, msg.File, msg.Line, file, want)
+		}
+		if msg.Function != "github.com/scor2k/go-vector-logger.TestIncludeCallerReportsCallSite" {
+			t.Errorf("message %d reported function %q", i, msg.Function)
+		}
+	}
+}
+
+func TestCaptureCallerMatchesRuntimeCaller(t *testing.T) {
+	for i := 0; i < 2; i++ { // Resolved first, then cached.
+		c, ok := captureCaller(1)
+		_, file, line, _ := runtime.Caller(0)
+		if !ok || c.File != file || c.Line != line-1 {
+			t.Errorf("captureCaller = %s:%d, want %s:%d", c.File, c.Line, file, line-1)
+		}
+	}
+}
+
+// naiveCaller resolves the caller with runtime.Caller and runtime.FuncForPC on
+// every call, as captureCaller would without its cache.
+func naiveCaller(skip int) (caller, bool) {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return caller{}, false
+	}
+	var function string
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		function = fn.Name()
+	}
+	return caller{File: file, Line: line, Function: function}, true
+}
+
+func BenchmarkCallerNaive(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = naiveCaller(1)
+	}
+}
+
+func BenchmarkCallerCached(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = captureCaller(1)
+	}
+}
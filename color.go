@@ -0,0 +1,56 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const colorReset = "\x1b[0m"
+
+// levelColors are the ANSI colors of the level token of printed messages.
+var levelColors = map[string]string{
+	TRACE: "\x1b[90m", // Gray.
+	DEBUG: "\x1b[36m", // Cyan.
+	INFO:  "\x1b[32m", // Green.
+	WARN:  "\x1b[33m", // Yellow.
+	ERROR: "\x1b[31m", // Red.
+	FATAL: "\x1b[35m", // Magenta.
+}
+
+// useColor reports whether printed messages are colorized, see Options.Color.
+func useColor(opts *Options) bool {
+	if opts.ForceColor {
+		return true
+	}
+	if !opts.Color {
+		return false
+	}
+	var console io.Writer = os.Stdout
+	if opts.ConsoleWriter != nil {
+		console = opts.ConsoleWriter
+	}
+	return isTerminal(console)
+}
+
+// isTerminal reports whether w is a character device, such as a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize returns a copy of msg whose level is padded like the default
+// console format and wrapped in the color of its level.
+func colorize(msg *Message) *Message {
+	color, ok := levelColors[msg.level]
+	if !ok {
+		return msg
+	}
+	colored := *msg
+	colored.Level = color + fmt.Sprintf("%5s", msg.Level) + colorReset
+	return &colored
+}
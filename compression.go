@@ -0,0 +1,72 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+// Compression selects how network payloads are compressed, see
+// Options.Compression.
+type Compression string
+
+const (
+	CompressionNone Compression = "none" // Payloads are sent as is (default).
+	CompressionGzip Compression = "gzip" // Payloads are gzip-compressed.
+)
+
+// validateCompression checks Options.Compression against the protocol.
+func validateCompression(opts *Options) error {
+	switch opts.Compression {
+	case "", CompressionNone:
+		return nil
+	case CompressionGzip:
+		if opts.Protocol == "udp" {
+			return fmt.Errorf("gzip compression is not supported with the udp protocol")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression %q, expected none or gzip", opts.Compression)
+	}
+}
+
+// compressing reports whether network payloads are gzip-compressed.
+func (l *VectorLogger) compressing() bool {
+	return l.Options.Compression == CompressionGzip
+}
+
+// writeGzip writes data to the gzip stream of the current connection, which
+// is started on the first write, and flushes it so that Vector can decode the
+// data right away. The caller must hold l.mu and l.conn must be set.
+func (l *VectorLogger) writeGzip(data []byte) error {
+	if l.gzip == nil {
+		l.gzip = gzip.NewWriter(l.connWriter())
+	}
+	if _, err := l.gzip.Write(data); err != nil {
+		return err
+	}
+	return l.gzip.Flush()
+}
+
+// closeGzip ends the gzip stream of the current connection, if any, before
+// the connection is closed. The caller must hold l.mu.
+func (l *VectorLogger) closeGzip() {
+	if l.gzip == nil {
+		return
+	}
+	_ = l.gzip.Close()
+	l.gzip = nil
+}
+
+// gzipBody returns data compressed as a single gzip member.
+func gzipBody(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package go_vector_logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+)
+
+// gunzipLines decompresses data and decodes every line as a message.
+func gunzipLines(t *testing.T, data []byte) []Message {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("not a gzip stream: %v", err)
+	}
+	var messages []Message
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("cannot decode %q: %v", scanner.Text(), err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("cannot decompress: %v", err)
+	}
+	return messages
+}
+
+func TestGzipOverTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	logger, err := New("test", "INFO", "127.0.0.1", int64(ln.Addr().(*net.TCPAddr).Port), Options{Compression: CompressionGzip})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("first")
+	logger.WarnFields("second", map[string]interface{}{"key": "value"})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	messages := gunzipLines(t, <-received)
+	if len(messages) != 2 || messages[0].Message != "first" || messages[1].Fields["key"] != "value" {
+		t.Errorf("decompressed %+v", messages)
+	}
+}
+
+func TestGzipOverHTTP(t *testing.T) {
+	sink := newHTTPSink(t)
+	logger, err := New("test", "INFO", "", 0, Options{Protocol: "http", HTTPEndpoint: sink.URL, Compression: CompressionGzip})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("compressed")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	requests := sink.received()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	if got := requests[0].header.Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+	if messages := gunzipLines(t, requests[0].body); len(messages) != 1 || messages[0].Message != "compressed" {
+		t.Errorf("decompressed %+v", messages)
+	}
+}
@@ -0,0 +1,783 @@
+package go_vector_logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultTimeoutDuration   = time.Minute           // Idle time after which the connection to Vector is closed.
+	minTimeoutDuration       = time.Second           // Replaces non-positive values passed to SetTimeoutDuration.
+	defaultCloseTimeout      = 30 * time.Second      // How long Close waits for the background goroutines.
+	defaultIdleCheckInterval = 10 * time.Second      // How often manageConnection looks for an idle connection.
+	minIdleCheckInterval     = 10 * time.Millisecond // Floor for Options.IdleCheckInterval.
+	defaultConnectRetryDelay = time.Second           // Wait between startup attempts when Options.ConnectRetryDelay is not set.
+)
+
+// core holds the connection to Vector and the state of the goroutine managing
+// it. It is created by New and shared by every logger derived from
+// the same root. Fields are guarded by mu unless noted otherwise.
+type core struct {
+	mu               sync.Mutex
+	conn             net.Conn
+	gzip             *gzip.Writer  // Compressed stream over conn, see Options.Compression.
+	bufw             *bufio.Writer // Write buffer in front of conn, see Options.BufferedWrites.
+	unflushed        int           // Messages written to bufw since its last flush.
+	lost             bool          // Set when conn failed; the next connection is a reconnect.
+	lastActivityTime time.Time
+	timeout          time.Duration
+	jitter           time.Duration   // Added to timeout for the current connection, see Options.TimeoutJitter.
+	stopChan         chan struct{}   // Closed to stop the background goroutines; guarded by queueMu.
+	managed          bool            // Set once the connection manager runs; guarded by queueMu.
+	wg               sync.WaitGroup  // Background goroutines stopped by stopChan.
+	worker           sync.WaitGroup  // Async worker, stopped by closing queue.
+	draining         atomic.Bool     // Set while the async worker delivers a message, see onWorker.
+	level            atomic.Value    // Current level (string), read without taking mu.
+	severity         atomic.Int32    // Severity of level, the only state read by disabled log calls.
+	errs             []error         // Errors recorded under mu, reported by unlock.
+	debugLines       []string        // Diagnostics recorded under mu, printed by unlock.
+	stats            counters        // Delivery counters, updated atomically.
+	seq              atomic.Uint64   // Last sequence number handed out, see Options.SequenceNumbers.
+	limiter          rateLimiter     // Options.RateLimit bucket, guarded by its own mutex.
+	deduper          deduper         // Options.DedupWindow state, guarded by its own mutex.
+	hostname         string          // Resolved by New when Options.IncludeHostname is set; read-only.
+	pid              int             // Set by New when Options.IncludePID is set; read-only.
+	color            bool            // Set by New when printed messages are colorized; read-only.
+	redactKeys       map[string]bool // Options.RedactKeys in lower case, set by New; read-only.
+	lastErr          error           // Most recent delivery error, cleared on success.
+	lastErrTime      time.Time
+	backoff          BackoffState
+	failoverUntil    time.Time      // While in the future, writes go to Options.Fallback.
+	nextAddr         int            // Index of the resolved address to dial first, see Options.ResolveEveryReconnect.
+	offline          []offlineEntry // Encoded messages kept while Vector is unreachable, oldest first.
+	batch            bytes.Buffer
+	batched          []batchRecord   // Messages encoded in batch, oldest first.
+	failures         []failedMessage // Messages of failed batch writes, handled by unlock.
+
+	// queue feeds the async worker. It is guarded, with stopChan, by queueMu
+	// rather than mu so that callers blocked on a full queue never hold the
+	// lock the worker needs.
+	queueMu sync.RWMutex
+	queue   chan *Message
+
+	consoleMu sync.Mutex // Serializes writes to Options.ConsoleWriter.
+
+	closing      atomic.Bool                    // Set by the first call to Close, which later calls skip.
+	closed       atomic.Bool                    // Set once Close has drained the async queue and stopped the batcher.
+	memory       atomic.Int64                   // Bytes held by the async queue, the batch, the offline buffer and the write buffer.
+	reporting    atomic.Int32                   // Number of reportError calls running Options.OnError.
+	callbacks    atomic.Int32                   // Number of Options.OnError and Options.FailureHandler calls running.
+	printFailing atomic.Bool                    // Set while printing messages fails, so it is reported once.
+	liveConn     atomic.Pointer[net.Conn]       // Copy of conn that Close can reach without mu.
+	output       atomic.Pointer[writerOverride] // Destination set by SetWriter, replacing Options.Writer.
+
+	writersMu sync.Mutex                // Guards writers.
+	writers   map[*levelWriter]struct{} // Writers holding an incomplete line, logged by Flush and Close.
+}
+
+// newCore returns the connection state for a new root logger.
+func newCore(level string) *core {
+	c := &core{
+		timeout:  defaultTimeoutDuration,
+		stopChan: make(chan struct{}),
+	}
+	c.setLevel(level)
+	return c
+}
+
+// setLevel stores level, and its severity for shouldLog.
+func (c *core) setLevel(level string) {
+	c.level.Store(level)
+	severity, ok := levelSeverity[level]
+	if !ok {
+		severity = levelSeverity[INFO]
+	}
+	c.severity.Store(int32(severity))
+}
+
+// SetTimeoutDuration sets how long the connection to Vector may stay idle
+// before it is closed; the next message reconnects. It is safe to call while
+// the logger is in use and takes effect on the next idle check. Non-positive
+// durations are replaced by one second.
+func (l *VectorLogger) SetTimeoutDuration(d time.Duration) {
+	if l.core == nil {
+		return
+	}
+	if d <= 0 {
+		d = minTimeoutDuration
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.timeout = d
+}
+
+// IsConnected reports whether the logger currently holds a connection to
+// Vector. It is a best-effort snapshot: the peer may already have closed the
+// socket, which is only noticed on the next write.
+func (l *VectorLogger) IsConnected() bool {
+	if l.core == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.conn != nil
+}
+
+// Reconnect closes the connection to Vector and dials a new one right away,
+// ignoring the reconnect backoff, e.g. after the endpoint behind VectorHost
+// changed. It is safe to call while the logger is in use. Loggers writing to
+// a writer or over HTTP have no connection and return nil, as do closed
+// loggers, which only close the current connection.
+func (l *VectorLogger) Reconnect() error {
+	if !l.usesNetwork() || l.usesHTTP() {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+	l.closeConnection()
+	if l.closed.Load() {
+		return nil
+	}
+	if err := l.establishConnection(); err != nil {
+		l.setLastError(err)
+		return err
+	}
+	l.backoff = BackoffState{}
+	l.failBack()
+	return nil
+}
+
+// Ping reports whether the logger can reach Vector right now, e.g. for a
+// readiness probe: it dials Vector when there is no connection, ignoring the
+// reconnect backoff, and checks that the peer still holds an existing TCP
+// connection, dialing again otherwise. Nothing is written to Vector. Loggers
+// writing to a writer or over HTTP have no connection and return nil.
+func (l *VectorLogger) Ping() error {
+	if !l.usesNetwork() || l.usesHTTP() {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+	if l.conn != nil && l.network() == "tcp" && !l.connectionAlive() {
+		l.deferDebugf("connection to vector on %s lost, reconnecting", l.address())
+		l.loseConnection()
+	}
+	if l.conn == nil {
+		if err := l.establishConnection(); err != nil {
+			l.setLastError(err)
+			return err
+		}
+		l.backoff = BackoffState{}
+		l.failBack()
+	}
+	l.lastActivityTime = time.Now()
+	if l.Options.NoPersistentConnection || l.closed.Load() {
+		l.closeConnection()
+	}
+	return nil
+}
+
+// Flush blocks until the messages logged so far have been written: the
+// incomplete lines of Writer are logged, in async mode it waits for the
+// worker to drain the queue, the pending batch is written out, and messages
+// kept in the offline buffer get one more delivery attempt. It returns the
+// error of that attempt, if any.
+func (l *VectorLogger) Flush() error {
+	if l.core == nil {
+		return nil
+	}
+	l.flushWriters()
+	l.drainQueue()
+
+	l.mu.Lock()
+	defer l.unlock()
+	if !l.usesNetwork() {
+		return nil
+	}
+	if err := l.flushBatch(); err != nil {
+		return err
+	}
+	if err := l.flushBuffered(); err != nil {
+		l.loseConnection()
+		return err
+	}
+	if len(l.offline) == 0 {
+		return nil
+	}
+	if l.conn == nil && !l.usesHTTP() {
+		if err := l.reconnect(); err != nil {
+			return err
+		}
+	}
+	return l.flushOffline()
+}
+
+// Close stops the connection manager, delivers the messages still queued by
+// the async worker or pending in the batch and closes the connection to
+// Vector, waiting at most 30 seconds (see CloseWithTimeout). Logging after
+// Close is supported: each message is then delivered synchronously over its
+// own connection, closed right after the write, since no goroutine is left
+// to close idle connections.
+// Closing a logger derived with With, or closing a logger again, is a no-op
+// returning nil.
+func (l *VectorLogger) Close() error {
+	return l.CloseWithTimeout(defaultCloseTimeout)
+}
+
+// CloseWithTimeout is like Close but gives up waiting for the background
+// goroutines after d: the connection is then closed forcibly, which unblocks
+// any pending write, and an error is returned.
+func (l *VectorLogger) CloseWithTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return l.shutdown(ctx, func() error {
+		return fmt.Errorf("timed out after %v waiting for pending log messages, connection to vector on %s closed", d, l.address())
+	})
+}
+
+// Shutdown is like Close but gives up waiting for the background goroutines
+// once ctx is done, e.g. at the deadline of a graceful shutdown: the
+// connection is then closed forcibly and an error wrapping ctx.Err() is
+// returned. An already expired ctx closes the logger without waiting.
+func (l *VectorLogger) Shutdown(ctx context.Context) error {
+	return l.shutdown(ctx, func() error {
+		return fmt.Errorf("gave up waiting for pending log messages, connection to vector on %s closed: %w", l.address(), ctx.Err())
+	})
+}
+
+// shutdown closes the logger, waiting for the background goroutines until
+// ctx is done and returning the error built by expired then. The teardown
+// runs in a fixed order so that no buffered message is lost or written to a
+// closed connection:
+//
+//  1. the incomplete lines of Writer and the deduplicated messages are
+//     flushed and the async queue is closed, so that later messages are
+//     delivered synchronously;
+//  2. the async worker delivers the queued messages;
+//  3. the batcher, the buffered-writes flusher and the connection manager,
+//     which runs the heartbeat, are stopped;
+//  4. the batch, the offline buffer, the gzip stream and the write buffer
+//     are flushed and the connection is closed.
+//
+// Steps 2 and 3 are bounded by ctx; when it is done first, the connection
+// is closed forcibly instead of step 4.
+func (l *VectorLogger) shutdown(ctx context.Context, expired func() error) error {
+	if l.core == nil || l.derived || l.closing.Swap(true) {
+		return nil
+	}
+
+	l.flushWriters()
+	l.flushDedup(nil)
+	l.queueMu.Lock()
+	queue := l.queue
+	if queue != nil {
+		close(queue)
+		l.queue = nil
+	}
+	l.queueMu.Unlock()
+	var finished bool
+	if queue != nil && l.onWorker() {
+		// Closed from OnError or FailureHandler: the worker cannot wait for
+		// itself, so it delivers the rest of the queue here.
+		for msg := range queue {
+			l.processQueued(msg)
+		}
+		finished = true
+	} else {
+		finished = waitGroup(ctx, &l.worker)
+	}
+
+	l.queueMu.Lock()
+	if l.stopChan != nil {
+		close(l.stopChan)
+		l.stopChan = nil
+	}
+	l.queueMu.Unlock()
+	finished = finished && waitGroup(ctx, &l.wg)
+
+	// Only now that the queue and the batcher are drained do later messages
+	// go over their own connection: the drained ones still share the
+	// persistent connection and the batch flushed below.
+	l.closed.Store(true)
+	if !finished {
+		if conn := l.liveConn.Load(); conn != nil {
+			_ = (*conn).Close()
+		}
+		return expired()
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+	_ = l.flushBatch()
+	if l.Options.FlushOfflineOnClose && len(l.offline) > 0 {
+		l.flushOfflineOnClose()
+	}
+	if l.conn == nil {
+		return nil
+	}
+	l.closeGzip()
+	if err := l.flushBuffered(); err != nil {
+		l.deferError(err)
+	}
+	err := l.conn.Close()
+	l.setConn(nil)
+	if err != nil {
+		return fmt.Errorf("cannot close the connection to vector on: %s: %w", l.address(), err)
+	}
+	return nil
+}
+
+// waitGroup waits for wg until ctx is done and reports whether wg finished.
+// An already expired ctx does not wait.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// reportError hands an internal error to Options.OnError, or prints it on
+// Options.InternalErrorWriter. It must not be called while holding l.mu, so
+// that OnError may log.
+// Errors raised while OnError runs, e.g. because it logs through this logger
+// while Vector is down, are printed instead, so that they cannot recurse.
+func (l *VectorLogger) reportError(err error) {
+	if l.Options.OnError != nil && l.core != nil {
+		if l.reporting.Add(1) == 1 {
+			defer l.reporting.Add(-1)
+			l.callback(func() { l.Options.OnError(err) })
+			return
+		}
+		l.reporting.Add(-1)
+	}
+	_, _ = fmt.Fprintf(l.internalErrorWriter(), "[ERROR] %v\n", err)
+}
+
+// callback runs f, a call to Options.OnError or Options.FailureHandler,
+// counted in l.callbacks, see onWorker.
+func (l *VectorLogger) callback(f func()) {
+	if l.core == nil {
+		f()
+		return
+	}
+	l.callbacks.Add(1)
+	defer l.callbacks.Add(-1)
+	f()
+}
+
+// internalErrorWriter returns the writer internal errors are printed on.
+func (l *VectorLogger) internalErrorWriter() io.Writer {
+	if l.Options.InternalErrorWriter != nil {
+		return l.Options.InternalErrorWriter
+	}
+	return os.Stderr
+}
+
+// deferError records err to be reported once l.mu is released by unlock. The
+// caller must hold l.mu.
+func (l *VectorLogger) deferError(err error) {
+	l.errs = append(l.errs, err)
+}
+
+// debugf prints a diagnostic line on Options.InternalErrorWriter when
+// Options.Debug is set. It must not be called while holding l.mu.
+func (l *VectorLogger) debugf(format string, v ...interface{}) {
+	if l.Options.Debug {
+		_, _ = fmt.Fprintf(l.internalErrorWriter(), "[DEBUG] "+format+"\n", v...)
+	}
+}
+
+// deferDebugf records a diagnostic line to be printed once l.mu is released
+// by unlock. The caller must hold l.mu.
+func (l *VectorLogger) deferDebugf(format string, v ...interface{}) {
+	if l.Options.Debug {
+		l.debugLines = append(l.debugLines, fmt.Sprintf(format, v...))
+	}
+}
+
+// unlock releases l.mu, passes the messages of failed batch writes to the
+// failure handling and reports the errors and diagnostics recorded while it
+// was held.
+func (l *VectorLogger) unlock() {
+	errs, debugLines, failures := l.errs, l.debugLines, l.failures
+	l.errs, l.debugLines, l.failures = nil, nil, nil
+	l.mu.Unlock()
+	for _, f := range failures {
+		if f.logger != nil {
+			f.logger.handleFailure(f.msg, f.err)
+		} else {
+			l.handleFailure(f.msg, f.err)
+		}
+	}
+	for _, err := range errs {
+		l.reportError(err)
+	}
+	for _, line := range debugLines {
+		l.debugf("%s", line)
+	}
+}
+
+// startConnectionManager starts the goroutine closing idle connections,
+// unless it already runs or the logger is closed.
+func (l *VectorLogger) startConnectionManager() {
+	l.queueMu.Lock()
+	defer l.queueMu.Unlock()
+	if l.managed || l.stopChan == nil {
+		return
+	}
+	l.managed = true
+	l.wg.Add(1)
+	go l.manageConnection(l.stopChan)
+}
+
+// manageConnection periodically closes the connection once it has been idle
+// for longer than the timeout, and probes it with Options.Heartbeat, until
+// stop is closed.
+func (l *VectorLogger) manageConnection(stop <-chan struct{}) {
+	defer l.wg.Done()
+	l.debugf("connection manager started for vector on %s", l.address())
+	defer l.debugf("connection manager stopped")
+
+	ticker := time.NewTicker(l.idleCheckInterval())
+	defer ticker.Stop()
+	heartbeats, stopHeartbeats := l.heartbeatTicker()
+	defer stopHeartbeats()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if timeout := l.timeout + l.jitter; l.conn != nil && time.Since(l.lastActivityTime) > timeout {
+				l.deferDebugf("closing the connection to vector on %s, idle for more than %v", l.address(), timeout)
+				l.closeConnection()
+			}
+			l.unlock()
+		case <-heartbeats:
+			l.mu.Lock()
+			l.heartbeat()
+			l.unlock()
+		}
+	}
+}
+
+// idleCheckInterval returns the interval between two idle checks.
+func (l *VectorLogger) idleCheckInterval() time.Duration {
+	interval := l.Options.IdleCheckInterval
+	if interval <= 0 {
+		return defaultIdleCheckInterval
+	}
+	if interval < minIdleCheckInterval {
+		return minIdleCheckInterval
+	}
+	return interval
+}
+
+// establishConnection dials Vector. The caller must hold l.mu.
+func (l *VectorLogger) establishConnection() error {
+	if l.Options.Conn != nil || l.Options.Reconnect != nil {
+		return l.replaceConnection()
+	}
+
+	var conn net.Conn
+	var err error
+	if l.Options.ResolveEveryReconnect {
+		conn, err = l.dialResolved()
+	} else {
+		conn, err = l.dialer().Dial(l.network(), l.address())
+	}
+	if err != nil {
+		return fmt.Errorf("cannot send logs to vector on: %s: %w", l.address(), err)
+	}
+	l.setConn(conn)
+	l.lastActivityTime = time.Now()
+	if l.Options.Debug {
+		l.deferDebugf("connected to vector on %s from %s", conn.RemoteAddr(), conn.LocalAddr())
+	}
+	return nil
+}
+
+// connectAtStartup opens the first connection to Vector, trying again up to
+// Options.ConnectRetries times after a failure.
+func (l *VectorLogger) connectAtStartup() error {
+	delay := l.Options.ConnectRetryDelay
+	if delay <= 0 {
+		delay = defaultConnectRetryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		l.mu.Lock()
+		err := l.establishConnection()
+		if err != nil && attempt < l.Options.ConnectRetries {
+			l.deferDebugf("connection attempt %d failed, retrying in %s: %v", attempt+1, delay, err)
+		}
+		l.unlock()
+		if err == nil || attempt >= l.Options.ConnectRetries {
+			return err
+		}
+		time.Sleep(delay)
+	}
+}
+
+// replaceConnection obtains a connection from Options.Reconnect, for loggers
+// given an established connection. The caller must hold l.mu.
+func (l *VectorLogger) replaceConnection() error {
+	if l.Options.Reconnect == nil {
+		return fmt.Errorf("cannot send logs to vector on: %s: connection lost and no Reconnect function is set", l.address())
+	}
+	conn, err := l.Options.Reconnect()
+	if err != nil {
+		return fmt.Errorf("cannot send logs to vector on: %s: %w", l.address(), err)
+	}
+	l.setConn(conn)
+	l.lastActivityTime = time.Now()
+	if l.Options.Debug {
+		l.deferDebugf("connected to vector on %s from %s", conn.RemoteAddr(), conn.LocalAddr())
+	}
+	return nil
+}
+
+// dialer returns the dialer used to connect to Vector.
+func (l *VectorLogger) dialer() *net.Dialer {
+	if l.Options.Dialer != nil {
+		return l.Options.Dialer
+	}
+	return &net.Dialer{Timeout: l.Options.DialTimeout, KeepAlive: l.Options.KeepAlive}
+}
+
+// writeOwnConn writes data on a connection to Vector dialed for this write
+// and closed after it, for loggers that dial per message.
+func (l *VectorLogger) writeOwnConn(data []byte) error {
+	conn, err := l.dialer().Dial(l.network(), l.address())
+	if err != nil {
+		return fmt.Errorf("cannot send logs to vector on: %s: %w", l.address(), err)
+	}
+	defer conn.Close()
+	if timeout := l.Options.WriteTimeout; timeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("cannot send data to vector: %w", err)
+	}
+	return nil
+}
+
+// closeConnection closes the current connection, if any. The caller must hold l.mu.
+func (l *VectorLogger) closeConnection() {
+	if l.conn == nil {
+		return
+	}
+	l.closeGzip()
+	if err := l.flushBuffered(); err != nil {
+		l.deferError(err)
+	}
+	if err := l.conn.Close(); err != nil {
+		l.deferError(fmt.Errorf("cannot close the connection to vector on: %s: %w", l.address(), err))
+	}
+	l.setConn(nil)
+}
+
+// loseConnection closes the current connection after it failed, so that the
+// next one counts in Stats.Reconnects. The caller must hold l.mu.
+func (l *VectorLogger) loseConnection() {
+	if l.conn == nil {
+		return
+	}
+	l.lost = true
+	l.closeConnection()
+}
+
+// setConn replaces the current connection. A new connection following a lost
+// persistent one counts as a reconnect. The caller must hold l.mu.
+func (l *VectorLogger) setConn(conn net.Conn) {
+	l.conn = conn
+	l.gzip = nil
+	l.releaseWriteBuffer()
+	l.unflushed = 0
+	if conn == nil {
+		l.liveConn.Store(nil)
+		return
+	}
+	if l.lost && !l.Options.NoPersistentConnection && !l.closed.Load() {
+		l.stats.reconnects.Add(1)
+	}
+	l.lost = false
+	l.liveConn.Store(&conn)
+	l.jitter = 0
+	if jitter := l.Options.TimeoutJitter; jitter > 0 {
+		l.jitter = time.Duration(rand.Int63n(int64(jitter)))
+	}
+}
+
+// writeToConn writes data holding the given number of messages to Vector,
+// or posts it with Protocol "http". It connects first if needed, waiting for
+// the connection when Options.DropPolicy is Block. A failed write is retried
+// once on a fresh connection, since the previous one may have been closed by
+// the peer. A non-zero deadline bounds each write. The caller must hold l.mu.
+func (l *VectorLogger) writeToConn(data []byte, messages int, level string, deadline time.Time) error {
+	if l.usesHTTP() {
+		return l.writeHTTP(data, messages, level, deadline)
+	}
+	if l.conn == nil {
+		if l.failingOver() {
+			return l.writeToFallback(data, messages, level, deadline)
+		}
+		err := l.reconnect()
+		if err != nil && l.Options.Fallback != nil {
+			return l.failOver(data, messages, level, deadline, err)
+		}
+		if err != nil && l.Options.DropPolicy == Block && !l.closing.Load() {
+			err = l.waitForConnection(err)
+		}
+		if err != nil {
+			if l.keepOffline(err) {
+				l.bufferOffline(data, messages, level)
+				return nil
+			}
+			return err
+		}
+	}
+	if err := l.flushOffline(); err != nil {
+		if l.keepOffline(err) {
+			l.bufferOffline(data, messages, level)
+			return nil
+		}
+		return err
+	}
+
+	n, err := l.writeWithDeadline(data, deadline)
+	if err != nil && l.retrySameConn(err) {
+		l.deferDebugf("write to vector on %s timed out after %d of %d bytes, retrying on the same connection", l.address(), n, len(data))
+		var written int
+		written, err = l.writeWithDeadline(data[n:], deadline)
+		n += written
+	}
+	if err != nil {
+		l.loseConnection()
+		rest, pending := l.unwritten(data, messages, n)
+		if n > 0 {
+			l.deferDebugf("write to vector on %s failed after %d of %d bytes, resending the last %d bytes", l.address(), n, len(data), len(rest))
+		}
+		if len(rest) > 0 {
+			if errConn := l.reconnect(); errConn != nil {
+				if l.Options.Fallback != nil {
+					return l.failOver(rest, pending, level, deadline, errConn)
+				}
+				if l.keepOffline(errConn) {
+					l.bufferOffline(rest, pending, level)
+					return nil
+				}
+				// Keep the write error, which tells why the message failed
+				return fmt.Errorf("%w: %w", errConn, err)
+			}
+			if _, errRetry := l.writeWithDeadline(rest, deadline); errRetry != nil {
+				l.loseConnection()
+				return fmt.Errorf("cannot send data to vector: %w", errRetry)
+			}
+		}
+	}
+
+	l.failBack()
+	l.lastActivityTime = time.Now()
+	l.countWritten(messages)
+	l.setLastError(nil)
+	if l.Options.NoPersistentConnection || l.closed.Load() {
+		l.closeConnection()
+	}
+	return nil
+}
+
+// unwritten returns the part of data holding the records that a write
+// stopped after n bytes did not pass whole to the connection, and the number
+// of messages among them. The records written whole reached the connection
+// complete and Vector already framed them: resending them would duplicate
+// them. The partial record may still reach Vector truncated when the
+// connection closes.
+func (l *VectorLogger) unwritten(data []byte, messages int, n int) ([]byte, int) {
+	offset := l.recordsEnd(data, n)
+	if offset == 0 {
+		return data, messages
+	}
+	rest := data[offset:]
+	if len(rest) == 0 {
+		return nil, 0
+	}
+	pending := messages - l.countRecords(data[:offset])
+	if pending < 1 {
+		pending = 1
+	}
+	return rest, pending
+}
+
+// writeWithDeadline writes data on the current connection and returns the
+// number of bytes written. The write is bounded by deadline unless it is
+// zero, and by Options.WriteTimeout from now when set; a fresh deadline is
+// computed for every write, including retries. The caller must hold l.mu and
+// l.conn must be set.
+func (l *VectorLogger) writeWithDeadline(data []byte, deadline time.Time) (int, error) {
+	if timeout := l.Options.WriteTimeout; timeout > 0 {
+		if timeoutDeadline := time.Now().Add(timeout); deadline.IsZero() || timeoutDeadline.Before(deadline) {
+			deadline = timeoutDeadline
+		}
+	}
+
+	conn := l.conn
+	if !deadline.IsZero() {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return 0, err
+		}
+		defer func() { _ = conn.SetWriteDeadline(time.Time{}) }()
+	}
+	if l.compressing() {
+		if err := l.writeGzip(data); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if l.Options.BufferedWrites {
+		// Which bytes of data reached the connection is unknown on error
+		if _, err := l.connWriter().Write(data); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	return conn.Write(data)
+}
+
+// retrySameConn reports whether a write that failed with err should resume
+// on the same connection rather than on a new one: only timeouts do, with
+// Options.RetryOnTimeoutSameConn, as the connection itself is still healthy.
+// A compressed stream is in an unknown state after a failed write, so it is
+// never resumed.
+func (l *VectorLogger) retrySameConn(err error) bool {
+	if !l.Options.RetryOnTimeoutSameConn || l.compressing() || l.Options.BufferedWrites {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,1337 @@
+package go_vector_logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testServer is a TCP listener standing in for Vector: it counts the
+// connections it accepts and sees closed, and records every line received.
+type testServer struct {
+	ln          net.Listener
+	conns       atomic.Int64
+	disconnects atomic.Int64
+
+	mu     sync.Mutex
+	lines  []string
+	active []net.Conn
+	wg     sync.WaitGroup
+}
+
+// newTestServer starts a testServer on a free local port, closed at the end
+// of the test.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerOn(t, 0)
+}
+
+// newTestServerOn starts a testServer on the given local port, e.g. one a
+// logger already tried while Vector was down.
+func newTestServerOn(t *testing.T, port int64) *testServer {
+	t.Helper()
+	return newTestServerAt(t, net.JoinHostPort("127.0.0.1", strconv.FormatInt(port, 10)))
+}
+
+// newTestServerAt starts a testServer listening on address.
+func newTestServerAt(t *testing.T, address string) *testServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	s := &testServer{ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *testServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.conns.Add(1)
+		s.mu.Lock()
+		s.active = append(s.active, conn)
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				s.mu.Lock()
+				s.lines = append(s.lines, scanner.Text())
+				s.mu.Unlock()
+			}
+			s.disconnects.Add(1)
+		}()
+	}
+}
+
+// dropConnections closes the connections accepted so far, as a restarting
+// Vector would.
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.active {
+		_ = conn.Close()
+	}
+	s.active = nil
+}
+
+// port returns the port the server listens on.
+func (s *testServer) port() int64 {
+	return int64(s.ln.Addr().(*net.TCPAddr).Port)
+}
+
+// close stops accepting connections and waits for the open ones to end.
+func (s *testServer) close() {
+	_ = s.ln.Close()
+	s.wg.Wait()
+}
+
+// received returns the lines received so far.
+func (s *testServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lines...)
+}
+
+// waitForLines waits up to a few seconds for n lines and returns the lines
+// received.
+func (s *testServer) waitForLines(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lines := s.received()
+		if len(lines) >= n || time.Now().After(deadline) {
+			return lines
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// countContaining returns how many lines contain substr.
+func countContaining(lines []string, substr string) int {
+	n := 0
+	for _, line := range lines {
+		if strings.Contains(line, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCloseDrainsOverPersistentConnection(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"async", Options{Async: true, BufferSize: 100}},
+		{"async and batch", Options{Async: true, BufferSize: 100, BatchSize: 10, BatchInterval: time.Hour}},
+		{"async and gzip", Options{Async: true, BufferSize: 100, Compression: CompressionGzip}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			logger, err := New("test", "INFO", "127.0.0.1", server.port(), tt.options)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			const count = 50
+			for i := 0; i < count; i++ {
+				logger.Infof("message %d", i)
+			}
+			if err := logger.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			if tt.options.Compression == CompressionGzip {
+				server.close()
+			} else if lines := server.waitForLines(t, count); len(lines) != count {
+				t.Fatalf("received %d lines, want %d", len(lines), count)
+			}
+			if got := server.conns.Load(); got != 1 {
+				t.Errorf("Close opened %d connections, want 1", got)
+			}
+		})
+	}
+}
+
+func TestLogAfterCloseUsesOwnConnection(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{Async: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("before")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	logger.Info("after one")
+	logger.Info("after two")
+
+	lines := server.waitForLines(t, 3)
+	if len(lines) != 3 {
+		t.Fatalf("received %d lines, want 3", len(lines))
+	}
+	if got := server.conns.Load(); got != 3 {
+		t.Errorf("got %d connections, want 3", got)
+	}
+	if logger.IsConnected() {
+		t.Error("connection kept open after Close")
+	}
+}
+
+func TestCloseDeliversEverythingWithoutLeaks(t *testing.T) {
+	server := newTestServer(t)
+	before := runtime.NumGoroutine()
+
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		Async:          true,
+		BufferSize:     1000,
+		BatchSize:      25,
+		BatchInterval:  time.Hour,
+		BufferedWrites: true,
+		FlushInterval:  time.Hour,
+		Heartbeat:      10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	const count = 510
+	for i := 0; i < count; i++ {
+		logger.Infof("message %d", i)
+	}
+	time.Sleep(30 * time.Millisecond) // Let a few heartbeats run.
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if lines := server.waitForLines(t, count); countContaining(lines, "message ") != count {
+		t.Fatalf("received %d messages, want %d", countContaining(lines, "message "), count)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines before New, %d after Close", before, after)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// closedPort returns a local port nothing listens on.
+func closedPort(t *testing.T) int64 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	port := int64(ln.Addr().(*net.TCPAddr).Port)
+	_ = ln.Close()
+	return port
+}
+
+func TestOnErrorLoggingWhileVectorIsDown(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"sync", Options{}},
+		{"async", Options{Async: true, BufferSize: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logger *VectorLogger
+			var calls atomic.Int64
+			internal := &syncBuffer{}
+			opts := tt.options
+			opts.LazyConnect = true
+			opts.InternalErrorWriter = internal
+			opts.OnError = func(err error) {
+				calls.Add(1)
+				logger.Errorf("internal error: %v", err)
+			}
+			var err error
+			logger, err = New("test", "INFO", "127.0.0.1", closedPort(t), opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 20; i++ {
+					logger.Infof("message %d", i)
+				}
+				_ = logger.CloseWithTimeout(time.Second)
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("logging from OnError did not return")
+			}
+
+			if calls.Load() == 0 {
+				t.Error("OnError was never called")
+			}
+			if !strings.Contains(internal.String(), "[ERROR]") {
+				t.Error("errors raised in OnError were not printed on InternalErrorWriter")
+			}
+		})
+	}
+}
+
+func TestVerifyConnectivityRetriesUntilVectorIsUp(t *testing.T) {
+	port := closedPort(t)
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{LazyConnect: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	// Attempts run at 0, 300 and 600ms: the server is up for the third.
+	listening := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(450 * time.Millisecond)
+		ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.FormatInt(port, 10)))
+		if err != nil {
+			t.Errorf("cannot listen: %v", err)
+		}
+		listening <- ln
+	}()
+	err = logger.VerifyConnectivity(5, 300*time.Millisecond)
+	if ln := <-listening; ln != nil {
+		_ = ln.Close()
+	}
+	if err != nil {
+		t.Fatalf("VerifyConnectivity: %v", err)
+	}
+	if got := logger.Stats().VerifyAttempts; got != 3 {
+		t.Errorf("VerifyAttempts = %d, want 3", got)
+	}
+}
+
+func TestVerifyConnectivityReportsEveryAttempt(t *testing.T) {
+	logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{LazyConnect: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	err = logger.VerifyConnectivity(2, time.Millisecond)
+	if err == nil {
+		t.Fatal("VerifyConnectivity succeeded with Vector down")
+	}
+	for _, want := range []string{"after 2 attempts", "attempt 1/2", "attempt 2/2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q lacks %q", err, want)
+		}
+	}
+	if got := logger.Stats().VerifyAttempts; got != 2 {
+		t.Errorf("VerifyAttempts = %d, want 2", got)
+	}
+}
+
+func TestStreamHeaderIdentifiesLevel(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "DEBUG", "127.0.0.1", server.port(), Options{StreamHeader: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("debug message")
+	logger.Warn("warn message")
+	logger.Error("error message")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := server.waitForLines(t, 3)
+	if len(lines) != 3 {
+		t.Fatalf("received %d lines, want 3", len(lines))
+	}
+	for i, want := range []string{DEBUG, WARN, ERROR} {
+		level := LevelForStreamID(lines[i][0])
+		var msg Message
+		if err := json.Unmarshal([]byte(lines[i][1:]), &msg); err != nil {
+			t.Fatalf("cannot decode %q: %v", lines[i], err)
+		}
+		if level != want || msg.Level != want {
+			t.Errorf("line %d: header maps to %q, level %q, want %q", i, level, msg.Level, want)
+		}
+	}
+}
+
+func TestFailureHandlerCapturesUndeliveredMessages(t *testing.T) {
+	var mu sync.Mutex
+	var deadLetters []string
+	logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{
+		LazyConnect: true,
+		FailureHandler: func(msg *Message, err error) error {
+			mu.Lock()
+			defer mu.Unlock()
+			deadLetters = append(deadLetters, msg.Message)
+			return nil
+		},
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("first")
+	logger.Warn("second")
+	_ = logger.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"first", "second"}; strings.Join(deadLetters, ",") != strings.Join(want, ",") {
+		t.Errorf("dead letters = %q, want %q", deadLetters, want)
+	}
+	if dropped := logger.Stats().Dropped; dropped != 0 {
+		t.Errorf("Dropped = %d, want 0", dropped)
+	}
+}
+
+func TestSetTimeoutDuration(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{IdleCheckInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	logger.SetTimeoutDuration(-time.Second)
+	logger.mu.Lock()
+	timeout := logger.timeout
+	logger.mu.Unlock()
+	if timeout != minTimeoutDuration {
+		t.Errorf("timeout after SetTimeoutDuration(-1s) = %v, want %v", timeout, minTimeoutDuration)
+	}
+
+	logger.SetTimeoutDuration(50 * time.Millisecond)
+	logger.Info("message")
+	deadline := time.Now().Add(5 * time.Second)
+	for logger.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if logger.IsConnected() {
+		t.Error("idle connection not closed after the short timeout")
+	}
+}
+
+func TestUDPTransport(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer pc.Close()
+	port := int64(pc.LocalAddr().(*net.UDPAddr).Port)
+
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{Protocol: "udp"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	logger.Info("first")
+	logger.Warn("second")
+
+	for _, want := range []string{"first", "second"} {
+		buf := make([]byte, 65536)
+		_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom: %v", err)
+		}
+		var msg Message
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			t.Fatalf("cannot decode datagram %q: %v", buf[:n], err)
+		}
+		if msg.Message != want {
+			t.Errorf("received %q, want %q", msg.Message, want)
+		}
+	}
+}
+
+func TestIsConnected(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if !logger.IsConnected() {
+		t.Error("IsConnected = false after New")
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if logger.IsConnected() {
+		t.Error("IsConnected = true after Close")
+	}
+
+	lazy, err := New("test", "INFO", "127.0.0.1", server.port(), Options{LazyConnect: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer lazy.Close()
+	if lazy.IsConnected() {
+		t.Error("IsConnected = true before the first message with LazyConnect")
+	}
+	lazy.Info("connect")
+	if !lazy.IsConnected() {
+		t.Error("IsConnected = false after the first message")
+	}
+}
+
+func TestIdleCheckIntervalClosesIdleConnection(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{IdleCheckInterval: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	logger.SetTimeoutDuration(200 * time.Millisecond)
+	logger.Info("only message")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.disconnects.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if server.disconnects.Load() == 0 {
+		t.Error("server saw no disconnect of the idle connection")
+	}
+}
+
+func TestWriteTimeoutWithPeerNotReading(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		defer ln.Close() // Accepts a single connection, then refuses the reconnect.
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn // Kept open, never read.
+		}
+	}()
+
+	logger, err := New("test", "INFO", "127.0.0.1", int64(ln.Addr().(*net.TCPAddr).Port), Options{
+		WriteTimeout:        100 * time.Millisecond,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	conn := <-accepted
+	defer conn.Close()
+
+	big := strings.Repeat("x", 64<<10)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		start := time.Now()
+		err := logger.TryInfo(big)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("write blocked for %v despite WriteTimeout", elapsed)
+		}
+		if err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("writes never failed with the peer not reading")
+		}
+	}
+	if failed := logger.Stats().Failed; failed == 0 {
+		t.Error("the timed out write was not counted as failed")
+	}
+}
+
+func TestExtraWritersReceiveEveryMessage(t *testing.T) {
+	server := newTestServer(t)
+	extra := &syncBuffer{}
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{ExtraWriters: []io.Writer{extra}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("first")
+	logger.Error("second")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := server.waitForLines(t, 2)
+	if got := strings.Join(lines, "\n") + "\n"; got != extra.String() {
+		t.Errorf("extra writer received %q, server %q", extra.String(), got)
+	}
+	if countContaining(lines, `"message":"first"`) != 1 || countContaining(lines, `"message":"second"`) != 1 {
+		t.Errorf("server received %q", lines)
+	}
+}
+
+func TestNoPersistentConnectionDialsPerMessage(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{NoPersistentConnection: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	const count = 3
+	for i := 0; i < count; i++ {
+		logger.Infof("message %d", i)
+		if logger.IsConnected() {
+			t.Errorf("connection kept open after message %d", i)
+		}
+	}
+
+	server.waitForLines(t, count)
+	deadline := time.Now().Add(5 * time.Second)
+	for server.disconnects.Load() < count && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if conns, disconnects := server.conns.Load(), server.disconnects.Load(); conns != count || disconnects != count {
+		t.Errorf("server saw %d connects and %d disconnects, want %d each", conns, disconnects, count)
+	}
+}
+
+func TestDialTimeoutBoundsNew(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	start := time.Now()
+	logger, err := New("test", "INFO", "10.255.255.1", 9000, Options{DialTimeout: timeout})
+	if elapsed := time.Since(start); elapsed > timeout+time.Second {
+		t.Errorf("New returned after %v with a dial timeout of %v", elapsed, timeout)
+	}
+	if err == nil {
+		_ = logger.Close()
+		t.Skip("the network of this host accepts connections to non-routable addresses")
+	}
+}
+
+func TestNewBeforeVectorIsUp(t *testing.T) {
+	port := closedPort(t)
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{LazyConnect: true})
+	if err != nil {
+		t.Fatalf("New failed with Vector down: %v", err)
+	}
+	defer logger.Close()
+	if logger.IsConnected() {
+		t.Fatal("IsConnected = true with Vector down")
+	}
+
+	server := newTestServerOn(t, port)
+	logger.Info("after start")
+	if lines := server.waitForLines(t, 1); countContaining(lines, "after start") != 1 {
+		t.Errorf("received %q, want the message logged once Vector is up", lines)
+	}
+}
+
+func TestTryMethodsReturnFailureMode(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{LazyConnect: true, InternalErrorWriter: &syncBuffer{}})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer logger.Close()
+		if err := logger.TryError("refused"); !errors.Is(err, syscall.ECONNREFUSED) {
+			t.Errorf("TryError = %v, want connection refused", err)
+		}
+	})
+
+	t.Run("write timeout", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer server.Close() // Never read.
+		logger, err := New("test", "INFO", "", 0, Options{Conn: client, WriteTimeout: 50 * time.Millisecond, InternalErrorWriter: &syncBuffer{}})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer logger.Close()
+		if err := logger.TryInfo("timed out"); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("TryInfo = %v, want a write timeout", err)
+		}
+	})
+}
+
+func TestConnOverPipe(t *testing.T) {
+	client, server := net.Pipe()
+	lines := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	logger, err := New("test", "INFO", "", 0, Options{Conn: client})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("over the pipe")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	_ = server.Close()
+
+	var got []string
+	for line := range lines {
+		got = append(got, line)
+	}
+	if len(got) != 1 || !strings.Contains(got[0], `"message":"over the pipe"`) {
+		t.Errorf("pipe received %q", got)
+	}
+}
+
+func TestMultiLineMessagesStayOneFrame(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatLogfmt, FormatRaw, FormatSyslog} {
+		t.Run(string(format), func(t *testing.T) {
+			server := newTestServer(t)
+			logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{Format: format})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logger.Error("panic: boom\n\ngoroutine 1 [running]:\r\nmain.main()\x00")
+			logger.Info("next")
+			if err := logger.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			server.close()
+			lines := server.received()
+			if len(lines) != 2 || !strings.Contains(lines[0], "boom") || !strings.Contains(lines[1], "next") {
+				t.Errorf("received %q, want one frame per message", lines)
+			}
+		})
+	}
+}
+
+func TestIPv6Hosts(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	port := int64(ln.Addr().(*net.TCPAddr).Port)
+	_ = ln.Close()
+
+	for _, host := range []string{"::1", "[::1]"} {
+		t.Run(host, func(t *testing.T) {
+			server := newTestServerAt(t, net.JoinHostPort("::1", strconv.FormatInt(port, 10)))
+
+			logger, err := New("test", "INFO", host, port)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logger.Info("over IPv6")
+			if err := logger.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if lines := server.waitForLines(t, 1); countContaining(lines, "over IPv6") != 1 {
+				t.Errorf("received %q", lines)
+			}
+		})
+	}
+
+	for _, host := range []string{"[::1", "::1]", "[localhost]"} {
+		if _, err := New("test", "INFO", host, port, Options{LazyConnect: true}); err == nil {
+			t.Errorf("New accepted host %q", host)
+		}
+	}
+}
+
+func TestHeartbeatReconnectsAfterDroppedPeer(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{Heartbeat: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	server.dropConnections()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.conns.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := server.conns.Load(); got != 2 {
+		t.Fatalf("server saw %d connections, want a reconnect on heartbeat", got)
+	}
+	logger.Info("after the heartbeat")
+	if lines := server.waitForLines(t, 1); countContaining(lines, "after the heartbeat") != 1 {
+		t.Errorf("received %q", lines)
+	}
+}
+
+func TestFailedNewOpensNoConnection(t *testing.T) {
+	server := newTestServer(t)
+	before := runtime.NumGoroutine()
+	for name, opts := range map[string]Options{
+		"stack trace level": {StackTraceLevel: "LOUD"},
+		"mirror level":      {MirrorToStderrLevel: "LOUD"},
+		"field names":       {FieldNames: FieldNames{Timestamp: "level"}},
+		"compression":       {Protocol: "udp", Compression: CompressionGzip},
+	} {
+		if _, err := New("test", "INFO", "127.0.0.1", server.port(), opts); err == nil {
+			t.Errorf("New accepted an invalid %s", name)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := server.conns.Load(); got != 0 {
+		t.Errorf("failed calls to New opened %d connections", got)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines before New, %d after", before, after)
+	}
+}
+
+func TestFailedStartupConnectionLeavesNothingRunning(t *testing.T) {
+	server := newTestServer(t)
+	before := runtime.NumGoroutine()
+	// Every attempt reaches Vector, then fails, e.g. in a handshake.
+	_, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		ConnectRetries:    2,
+		ConnectRetryDelay: time.Millisecond,
+		Async:             true,
+		BatchSize:         10,
+		Reconnect: func() (net.Conn, error) {
+			conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", server.port()))
+			if err != nil {
+				return nil, err
+			}
+			_ = conn.Close()
+			return nil, errors.New("handshake failed")
+		},
+	})
+	if err == nil {
+		t.Fatal("New succeeded although every connection attempt failed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := server.conns.Load(); got != 3 {
+		t.Errorf("server saw %d connections, want one per attempt", got)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines before New, %d after", before, after)
+	}
+}
+
+func TestDoubleClose(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{Async: true, BatchSize: 10, Heartbeat: time.Second})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("before")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if err := logger.With(map[string]interface{}{"child": true}).Close(); err != nil {
+		t.Errorf("Close of a child after its root: %v", err)
+	}
+	logger.Info("after")
+	if err := logger.Close(); err != nil {
+		t.Errorf("Close after logging after Close: %v", err)
+	}
+	if lines := server.waitForLines(t, 2); countContaining(lines, "before") != 1 || countContaining(lines, "after") != 1 {
+		t.Errorf("received %q", lines)
+	}
+}
+
+func TestReconnectOpensFreshConnection(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	logger.Info("first connection")
+	if err := logger.Reconnect(); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+	logger.Info("second connection")
+
+	if lines := server.waitForLines(t, 2); len(lines) != 2 {
+		t.Errorf("received %q", lines)
+	}
+	if got := server.conns.Load(); got != 2 {
+		t.Errorf("server saw %d connections, want 2", got)
+	}
+	if got := logger.Stats().Reconnects; got != 0 {
+		t.Errorf("Reconnects = %d after a requested reconnect, want 0", got)
+	}
+}
+
+func TestTimeoutJitterRange(t *testing.T) {
+	server := newTestServer(t)
+	const jitter = 50 * time.Millisecond
+	distinct := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{TimeoutJitter: jitter})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		logger.mu.Lock()
+		effective := logger.timeout + logger.jitter
+		base := logger.timeout
+		logger.mu.Unlock()
+		_ = logger.Close()
+
+		if effective < base || effective >= base+jitter {
+			t.Errorf("effective timeout %v outside [%v, %v)", effective, base, base+jitter)
+		}
+		distinct[effective] = true
+	}
+	if len(distinct) < 2 {
+		t.Error("every construction drew the same jitter")
+	}
+}
+
+// resetConn is a connection whose writes fail as if the peer reset it.
+type resetConn struct{ net.Conn }
+
+func (c resetConn) Write([]byte) (int, error) {
+	return 0, &net.OpError{Op: "write", Net: "tcp", Err: syscall.ECONNRESET}
+}
+
+// readPipe returns the client end of a net.Pipe whose server end is read
+// line by line into lines after delay.
+func readPipe(delay time.Duration, lines chan<- string) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		time.Sleep(delay)
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return client
+}
+
+func TestRetryOnTimeoutSameConn(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		lines := make(chan string, 10)
+		var redials atomic.Int64
+		logger, err := New("test", "INFO", "", 0, Options{
+			Conn:                   readPipe(150*time.Millisecond, lines),
+			Reconnect:              func() (net.Conn, error) { redials.Add(1); return readPipe(0, lines), nil },
+			WriteTimeout:           100 * time.Millisecond,
+			RetryOnTimeoutSameConn: true,
+		})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer logger.Close()
+		if err := logger.TryInfo("slow reader"); err != nil {
+			t.Fatalf("TryInfo: %v", err)
+		}
+		if got := <-lines; !strings.Contains(got, "slow reader") {
+			t.Errorf("received %q", got)
+		}
+		if got := redials.Load(); got != 0 {
+			t.Errorf("a timed out write redialed %d times, want a retry on the same connection", got)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		lines := make(chan string, 10)
+		client, server := net.Pipe()
+		defer server.Close()
+		var redials atomic.Int64
+		logger, err := New("test", "INFO", "", 0, Options{
+			Conn:                   resetConn{client},
+			Reconnect:              func() (net.Conn, error) { redials.Add(1); return readPipe(0, lines), nil },
+			WriteTimeout:           100 * time.Millisecond,
+			RetryOnTimeoutSameConn: true,
+		})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer logger.Close()
+		if err := logger.TryInfo("after reset"); err != nil {
+			t.Fatalf("TryInfo: %v", err)
+		}
+		if got := <-lines; !strings.Contains(got, "after reset") {
+			t.Errorf("received %q", got)
+		}
+		if got := redials.Load(); got != 1 {
+			t.Errorf("a reset connection redialed %d times, want 1", got)
+		}
+	})
+}
+
+func TestConnectRetriesWaitForVector(t *testing.T) {
+	port := closedPort(t)
+	servers := make(chan *testServer, 1)
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		servers <- newTestServerOn(t, port)
+	}()
+
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{ConnectRetries: 10, ConnectRetryDelay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	server := <-servers
+	if !logger.IsConnected() {
+		t.Fatal("New returned without a connection")
+	}
+	logger.Info("connected on retry")
+	if lines := server.waitForLines(t, 1); countContaining(lines, "connected on retry") != 1 {
+		t.Errorf("received %q", lines)
+	}
+
+	start := time.Now()
+	if _, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{ConnectRetries: 2, ConnectRetryDelay: 20 * time.Millisecond}); err == nil {
+		t.Error("New succeeded with Vector down after exhausting its retries")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("New gave up after %v, before its 2 retries", elapsed)
+	}
+}
+
+func TestPing(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{LazyConnect: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	if err := logger.Ping(); err != nil {
+		t.Errorf("Ping against a live server: %v", err)
+	}
+
+	down, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{LazyConnect: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer down.Close()
+	if err := down.Ping(); err == nil {
+		t.Error("Ping succeeded against a closed port")
+	}
+}
+
+func TestSequenceNumbersIncreaseByOne(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{SequenceNumbers: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	child := logger.With(map[string]interface{}{"child": true})
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			logger.Infof("message %d", i)
+		} else {
+			child.Infof("message %d", i)
+		}
+	}
+	logger.Debug("filtered, not numbered")
+	logger.Info("last")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := server.waitForLines(t, 11)
+	if len(lines) != 11 {
+		t.Fatalf("received %d lines, want 11", len(lines))
+	}
+	for i, line := range lines {
+		var msg Message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("cannot decode %q: %v", line, err)
+		}
+		if msg.Seq != uint64(i+1) {
+			t.Errorf("message %d has seq %d, want %d", i, msg.Seq, i+1)
+		}
+	}
+}
+
+// partialConn is a connection that passes the first record of a write and
+// a few bytes of the next to out, then fails as if the peer closed it.
+type partialConn struct {
+	net.Conn
+	out *syncBuffer
+}
+
+func (c partialConn) Write(p []byte) (int, error) {
+	n := bytes.IndexByte(p, '\n') + 10
+	if n > len(p) {
+		n = len(p)
+	}
+	_, _ = c.out.Write(p[:n])
+	return n, &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}
+}
+
+func TestPartialWriteResendsOnlyUnwrittenRecords(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	first := &syncBuffer{}
+	lines := make(chan string, 10)
+	logger, err := New("test", "INFO", "", 0, Options{
+		Conn:          partialConn{Conn: client, out: first},
+		Reconnect:     func() (net.Conn, error) { return readPipe(0, lines), nil },
+		BatchSize:     3,
+		BatchInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		logger.Infof("message %d", i)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	delivered := map[string]int{}
+	decode := func(line string) {
+		var msg Message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("cannot decode %q: %v", line, err)
+		}
+		delivered[msg.Message]++
+	}
+	written, cut, _ := strings.Cut(first.String(), "\n")
+	if cut == "" || strings.Contains(cut, "\n") {
+		t.Fatalf("first connection received %q, want one record and a cut one", first.String())
+	}
+	decode(written)
+	for i := 0; i < 2; i++ {
+		decode(<-lines)
+	}
+	for i := 0; i < 3; i++ {
+		if got := delivered[fmt.Sprintf("message %d", i)]; got != 1 {
+			t.Errorf("message %d delivered %d times, want once", i, got)
+		}
+	}
+}
+
+func TestBufferedWritesFlushWithinInterval(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		BufferedWrites: true,
+		FlushInterval:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	start := time.Now()
+	logger.Info("first")
+	logger.Info("second")
+	lines := server.waitForLines(t, 2)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("delivered after %v with a 50ms flush interval", elapsed)
+	}
+	if countContaining(lines, `"message":"`) != 2 {
+		t.Errorf("received %q", lines)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("expired context", func(t *testing.T) {
+		out := &gatedWriter{open: make(chan struct{})}
+		logger, err := New("test", "INFO", "", 0, Options{Writer: out, Async: true})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer close(out.open)
+		logger.Info("stuck")
+
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+		start := time.Now()
+		err = logger.Shutdown(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown returned %v, want context.DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Shutdown took %v with an expired context", elapsed)
+		}
+	})
+
+	t.Run("ample time", func(t *testing.T) {
+		server := newTestServer(t)
+		logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{Async: true, BufferSize: 200})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		for i := 0; i < 100; i++ {
+			logger.Infof("message %d", i)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := logger.Shutdown(ctx); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if lines := server.waitForLines(t, 100); len(lines) != 100 {
+			t.Errorf("received %d lines, want 100", len(lines))
+		}
+		if logger.IsConnected() {
+			t.Error("connection kept open after Shutdown")
+		}
+	})
+}
+
+func TestCloneOpensOwnConnection(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "WARN", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	clone, err := logger.With(map[string]interface{}{"component": "worker"}).Clone()
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for server.conns.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := server.conns.Load(); got != 2 {
+		t.Errorf("server accepted %d connections, want one per logger", got)
+	}
+	clone.Info("filtered")
+	clone.Warn("from clone")
+	if err := clone.Close(); err != nil {
+		t.Fatalf("Close clone: %v", err)
+	}
+	logger.Warn("from original")
+
+	lines := server.waitForLines(t, 2)
+	if len(lines) != 2 || countContaining(lines, `"message":"from clone","fields":{"component":"worker"}`) != 1 {
+		t.Errorf("received %q, want the clone to keep the level and fields", lines)
+	}
+	if countContaining(lines, "from original") != 1 {
+		t.Errorf("original stopped delivering after the clone closed: %q", lines)
+	}
+	if !logger.IsConnected() {
+		t.Error("closing the clone closed the original connection")
+	}
+}
+
+func TestWithSharesConnectionAndAccumulatesFields(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	request := logger.With(map[string]interface{}{"request_id": "abc", "step": 1})
+	step := request.With(map[string]interface{}{"step": 2, "user": "alice"})
+
+	step.Info("nested")
+	request.Info("child")
+	logger.Info("root")
+	if err := step.Close(); err != nil {
+		t.Fatalf("Close child: %v", err)
+	}
+	if !logger.IsConnected() {
+		t.Error("closing a child closed the shared connection")
+	}
+	request.Info("after child close")
+
+	lines := server.waitForLines(t, 4)
+	if got := server.conns.Load(); got != 1 {
+		t.Errorf("server accepted %d connections, want the children to share one", got)
+	}
+	want := []map[string]interface{}{
+		{"request_id": "abc", "step": 2.0, "user": "alice"},
+		{"request_id": "abc", "step": 1.0},
+		nil,
+		{"request_id": "abc", "step": 1.0},
+	}
+	for i, line := range lines {
+		got, _ := decodeLines(t, line)[0]["fields"].(map[string]interface{})
+		if len(got) != len(want[i]) || (len(got) > 0 && !reflect.DeepEqual(got, want[i])) {
+			t.Errorf("line %d has fields %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestInitLoggerDialsPerMessage(t *testing.T) {
+	server := newTestServer(t)
+	logger := &VectorLogger{}
+	logger.Init("a", "info", "127.0.0.1", server.port())
+	logger.Info("first")
+	logger.Info("second")
+
+	server.waitForLines(t, 2)
+	if got := server.conns.Load(); got != 2 {
+		t.Errorf("server accepted %d connections, want one per message", got)
+	}
+	if logger.IsConnected() {
+		t.Error("logger initialized by Init kept a connection open")
+	}
+}
+
+func TestStructLiteralLoggerDialsPerMessage(t *testing.T) {
+	server := newTestServer(t)
+	logger := &VectorLogger{Application: "a", Level: "INFO", VectorHost: "127.0.0.1", VectorPort: server.port()}
+	logger.Info("first")
+	if err := logger.TryWarn("second"); err != nil {
+		t.Fatalf("TryWarn: %v", err)
+	}
+	if err := logger.WriteRaw(ERROR, []string{"third", "fourth"}); err != nil {
+		t.Fatalf("WriteRaw: %v", err)
+	}
+
+	lines := server.waitForLines(t, 4)
+	for _, want := range []string{"first", "second", "third", "fourth"} {
+		if countContaining(lines, `"message":"`+want+`"`) != 1 {
+			t.Errorf("received %q, want %q once", lines, want)
+		}
+	}
+	if got := server.conns.Load(); got != 3 {
+		t.Errorf("server accepted %d connections, want one per write", got)
+	}
+
+	internal := &syncBuffer{}
+	down := &VectorLogger{Application: "a", Level: "INFO", VectorHost: "127.0.0.1", VectorPort: closedPort(t),
+		Options: Options{InternalErrorWriter: internal}}
+	if err := down.TryInfo("lost"); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("TryInfo returned %v, want the dial error", err)
+	}
+	down.Info("lost too")
+	if !strings.Contains(internal.String(), "cannot send logs to vector") {
+		t.Errorf("internal errors = %q, want the failed delivery reported", internal.String())
+	}
+}
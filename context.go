@@ -0,0 +1,62 @@
+package go_vector_logger
+
+import "context"
+
+// loggerContextKey is the context key under which ContextWithLogger stores a logger.
+type loggerContextKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying l, so middleware can stash an
+// enriched logger for handlers to retrieve with FromContext.
+func ContextWithLogger(ctx context.Context, l *VectorLogger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx by ContextWithLogger. When ctx
+// carries no logger it returns a zero-value VectorLogger, which discards every
+// message.
+func FromContext(ctx context.Context) *VectorLogger {
+	if l, ok := ctx.Value(loggerContextKey{}).(*VectorLogger); ok && l != nil {
+		return l
+	}
+	return &VectorLogger{}
+}
+
+// WithContext returns a child logger bound to ctx: its messages are not sent
+// once ctx is cancelled, and the deadline of ctx bounds the network write.
+func (l *VectorLogger) WithContext(ctx context.Context) *VectorLogger {
+	child := l.derive()
+	child.ctx = ctx
+	return child
+}
+
+// DebugContext logs a debug message bound to ctx.
+func (l *VectorLogger) DebugContext(ctx context.Context, message string) {
+	if !l.shouldLog(DEBUG) {
+		return
+	}
+	l.WithContext(ctx).sendMessage(message, DEBUG)
+}
+
+// InfoContext logs an info message bound to ctx, see WithContext.
+func (l *VectorLogger) InfoContext(ctx context.Context, message string) {
+	if !l.shouldLog(INFO) {
+		return
+	}
+	l.WithContext(ctx).sendMessage(message, INFO)
+}
+
+// WarnContext logs a warning message bound to ctx.
+func (l *VectorLogger) WarnContext(ctx context.Context, message string) {
+	if !l.shouldLog(WARN) {
+		return
+	}
+	l.WithContext(ctx).sendMessage(message, WARN)
+}
+
+// ErrorContext logs an error message bound to ctx.
+func (l *VectorLogger) ErrorContext(ctx context.Context, message string) {
+	if !l.shouldLog(ERROR) {
+		return
+	}
+	l.WithContext(ctx).sendMessage(message, ERROR)
+}
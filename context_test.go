@@ -0,0 +1,87 @@
+package go_vector_logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContextWithLogger(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	child := logger.With(map[string]interface{}{"request_id": "abc"})
+
+	ctx := ContextWithLogger(context.Background(), child)
+	if got := FromContext(ctx); got != child {
+		t.Fatalf("FromContext returned %p, want %p", got, child)
+	}
+	FromContext(ctx).Info("handled")
+	records := decodeLines(t, out.String())
+	if fields, _ := records[0]["fields"].(map[string]interface{}); fields["request_id"] != "abc" {
+		t.Errorf("fields = %v, want request_id=abc", fields)
+	}
+
+	nop := FromContext(context.Background())
+	if nop == nil {
+		t.Fatal("FromContext returned nil without a logger")
+	}
+	nop.Info("discarded")
+	nop.Errorf("discarded %d", 1)
+	if nop.IsConnected() {
+		t.Error("logger returned without a logger in the context is connected")
+	}
+}
+
+func TestCancelledContextSkipsWrite(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger.InfoContext(ctx, "cancelled")
+	logger.WithContext(ctx).Warn("cancelled too")
+	logger.InfoContext(context.Background(), "delivered")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := server.waitForLines(t, 1)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"message":"delivered"`) {
+		t.Errorf("received %q, want only the message with a live context", lines)
+	}
+}
+
+// traceKey is the context key of the fake trace extractor.
+type traceKey struct{}
+
+func TestTraceIDFromContext(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer: out,
+		TraceIDFromContext: func(ctx context.Context) (string, string) {
+			if ids, ok := ctx.Value(traceKey{}).([2]string); ok {
+				return ids[0], ids[1]
+			}
+			return "", ""
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), traceKey{}, [2]string{"4bf92f3577b34da6", "00f067aa0ba902b7"})
+	logger.InfoContext(ctx, "traced")
+	logger.InfoContext(context.Background(), "untraced")
+
+	records := decodeLines(t, out.String())
+	if records[0]["trace_id"] != "4bf92f3577b34da6" || records[0]["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("traced record = %v", records[0])
+	}
+	if _, ok := records[1]["trace_id"]; ok {
+		t.Errorf("untraced record = %v", records[1])
+	}
+}
@@ -0,0 +1,91 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// deduper collapses identical consecutive messages, see Options.DedupWindow.
+type deduper struct {
+	mu      sync.Mutex
+	last    *Message    // Copy of the first message of the current run of duplicates.
+	repeats int         // Duplicates of last suppressed so far.
+	timer   *time.Timer // Ends the current window.
+}
+
+// dedup reports whether msg repeats the previous message within the dedup
+// window and must be suppressed. When msg ends a run of duplicates, the
+// summary of that run is sent first.
+func (l *VectorLogger) dedup(msg *Message) bool {
+	if l.Options.DedupWindow <= 0 || l.core == nil {
+		return false
+	}
+
+	d := &l.deduper
+	d.mu.Lock()
+	if d.last != nil && d.last.level == msg.level && d.last.Message == msg.Message {
+		d.repeats++
+		d.mu.Unlock()
+		return true
+	}
+
+	summary := d.takeSummary(l)
+	// Keep a copy: msg goes on to be stamped and sent while d.last is read
+	// by later calls.
+	first := *msg
+	d.last = &first
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(l.Options.DedupWindow, func() { l.flushDedup(&first) })
+	d.mu.Unlock()
+
+	if summary != nil {
+		l.send(summary)
+	}
+	return false
+}
+
+// flushDedup ends the dedup window started by first, sending the summary of
+// its duplicates if there were any. A nil first ends whatever window is open.
+func (l *VectorLogger) flushDedup(first *Message) {
+	d := &l.deduper
+	d.mu.Lock()
+	if d.last == nil || (first != nil && d.last != first) {
+		d.mu.Unlock()
+		return
+	}
+	summary := d.takeSummary(l)
+	d.last = nil
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.mu.Unlock()
+
+	if summary != nil {
+		l.send(summary)
+	}
+}
+
+// takeSummary returns the summary message of the current run of duplicates,
+// or nil when nothing was suppressed, and resets the repeat count. The caller
+// must hold d.mu.
+func (d *deduper) takeSummary(l *VectorLogger) *Message {
+	if d.last == nil || d.repeats == 0 {
+		return nil
+	}
+
+	summary := *d.last
+	summary.time = l.now()
+	summary.Timestamp = l.timestamp(summary.time)
+	summary.Message = fmt.Sprintf("%s (repeated %d times)", d.last.Message, d.repeats)
+	summary.Fields = make(map[string]interface{}, len(d.last.Fields)+1)
+	for k, v := range d.last.Fields {
+		summary.Fields[k] = v
+	}
+	summary.Fields["repeated"] = d.repeats
+	d.repeats = 0
+	return &summary
+}
@@ -0,0 +1,77 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupConcurrentDuplicatesWithSequenceNumbers(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:          out,
+		DedupWindow:     time.Millisecond,
+		SequenceNumbers: true,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				if i%2 == 0 {
+					logger.Infof("distinct %d %d", g, i)
+				}
+				logger.Info("duplicate")
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "repeated") {
+		t.Error("no summary of the duplicates was sent")
+	}
+}
+
+func TestDedupCollapsesAndSummarizes(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, DedupWindow: time.Hour})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		logger.Warn("disk full")
+	}
+	logger.Info("other") // Ends the run of duplicates.
+	logger.Info("other")
+	if err := logger.Close(); err != nil { // Flushes the pending summary.
+		t.Fatalf("Close: %v", err)
+	}
+
+	var got []string
+	for _, record := range decodeLines(t, out.String()) {
+		entry := record["level"].(string) + " " + record["message"].(string)
+		if fields, ok := record["fields"].(map[string]interface{}); ok {
+			entry += fmt.Sprintf(" repeated=%v", fields["repeated"])
+		}
+		got = append(got, entry)
+	}
+	want := []string{
+		"WARN disk full",
+		"WARN disk full (repeated 3 times) repeated=3",
+		"INFO other",
+		"INFO other (repeated 1 times) repeated=1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("records:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
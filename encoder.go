@@ -0,0 +1,399 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPooledBufferSize keeps buffers grown by huge messages out of bufferPool.
+const maxPooledBufferSize = 64 << 10
+
+// bufferPool recycles the buffers messages are encoded into.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from bufferPool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to bufferPool. Its contents must no longer be referenced.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// Encoder serializes a log message into buf. Implementations must write exactly
+// one record terminated by a newline so line-based Vector sources keep working.
+type Encoder interface {
+	Encode(buf *bytes.Buffer, msg *Message) error
+}
+
+// Format selects how messages are serialized on the wire, see Options.Format.
+type Format string
+
+const (
+	FormatJSON   Format = "json"   // One JSON object per line (default).
+	FormatLogfmt Format = "logfmt" // timestamp=... application=... level=... message=...
+	FormatRaw    Format = "raw"    // Only the message text.
+	FormatSyslog Format = "syslog" // RFC 5424 syslog lines, see SyslogEncoder.
+)
+
+// encoderFor returns the Encoder implementing the format selected in opts.
+func encoderFor(opts *Options) (Encoder, error) {
+	switch opts.Format {
+	case "", FormatJSON:
+		return JSONEncoder{FieldNames: opts.FieldNames}, nil
+	case FormatLogfmt:
+		return LogfmtEncoder{FieldNames: opts.FieldNames}, nil
+	case FormatRaw:
+		return RawEncoder{KeepNewlines: opts.KeepNewlines}, nil
+	case FormatSyslog:
+		facility := defaultSyslogFacility
+		if opts.SyslogFacility != nil {
+			facility = *opts.SyslogFacility
+		}
+		if facility < 0 || facility > 23 {
+			return nil, fmt.Errorf("invalid syslog facility %d, expected 0-23", facility)
+		}
+		return SyslogEncoder{Facility: facility, KeepNewlines: opts.KeepNewlines}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", opts.Format)
+	}
+}
+
+// FieldNames overrides the keys of the timestamp, application, level and
+// message fields in JSON and logfmt output; empty names keep the defaults.
+type FieldNames struct {
+	Timestamp   string
+	Application string
+	Level       string
+	Message     string
+}
+
+// reservedFieldNames are the other top-level keys of a JSON message, which
+// FieldNames cannot reuse.
+var reservedFieldNames = []string{"event", "hostname", "pid", "seq", "file", "line", "function", "stacktrace", "trace_id", "span_id", "fields", "tags"}
+
+// withDefaults returns n with the default name in place of every empty one.
+func (n FieldNames) withDefaults() FieldNames {
+	if n.Timestamp == "" {
+		n.Timestamp = "timestamp"
+	}
+	if n.Application == "" {
+		n.Application = "application"
+	}
+	if n.Level == "" {
+		n.Level = "level"
+	}
+	if n.Message == "" {
+		n.Message = "message"
+	}
+	return n
+}
+
+// has reports whether name is one of the keys of n.
+func (n FieldNames) has(name string) bool {
+	return name == n.Timestamp || name == n.Application || name == n.Level || name == n.Message
+}
+
+// validate checks that the names are distinct and do not reuse another key
+// of the message.
+func (n FieldNames) validate() error {
+	n = n.withDefaults()
+	seen := make(map[string]bool, 4+len(reservedFieldNames))
+	for _, name := range reservedFieldNames {
+		seen[name] = true
+	}
+	for _, name := range []string{n.Timestamp, n.Application, n.Level, n.Message} {
+		if seen[name] {
+			return fmt.Errorf("invalid field names: %q conflicts with another key", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// EncoderSink pairs an Encoder with the writer that receives its output.
+type EncoderSink struct {
+	Encoder Encoder   // Encoder used to serialize every message for this sink.
+	Writer  io.Writer // Destination of the encoded messages.
+}
+
+// JSONEncoder encodes messages as newline-terminated JSON objects.
+type JSONEncoder struct {
+	FieldNames FieldNames // Keys of the timestamp, application, level and message fields.
+}
+
+// Encode implements Encoder.
+func (e JSONEncoder) Encode(buf *bytes.Buffer, msg *Message) error {
+	if e.FieldNames != (FieldNames{}) {
+		return e.encodeRenamed(buf, msg)
+	}
+	if msg.epoch {
+		// Emit the Unix milliseconds as a number; the outer Timestamp field
+		// shadows the one of the embedded message.
+		type message Message
+		return json.NewEncoder(buf).Encode(struct {
+			Timestamp json.Number `json:"timestamp"`
+			*message
+		}{json.Number(msg.Timestamp), (*message)(msg)})
+	}
+	return json.NewEncoder(buf).Encode(msg)
+}
+
+// encodeRenamed encodes msg with the keys of e.FieldNames: the four renamed
+// fields are written first, followed by the other fields of the message.
+func (e JSONEncoder) encodeRenamed(buf *bytes.Buffer, msg *Message) error {
+	names := e.FieldNames.withDefaults()
+	var timestamp interface{} = msg.Timestamp
+	if msg.epoch {
+		timestamp = json.Number(msg.Timestamp)
+	}
+
+	buf.WriteByte('{')
+	for i, pair := range [...]struct {
+		key   string
+		value interface{}
+	}{
+		{names.Timestamp, timestamp},
+		{names.Application, msg.Application},
+		{names.Level, msg.Level},
+		{names.Message, msg.Message},
+	} {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONPair(buf, pair.key, pair.value); err != nil {
+			return err
+		}
+	}
+
+	rest, err := json.Marshal(struct {
+		Event      string                 `json:"event,omitempty"`
+		Hostname   string                 `json:"hostname,omitempty"`
+		PID        int                    `json:"pid,omitempty"`
+		Seq        uint64                 `json:"seq,omitempty"`
+		File       string                 `json:"file,omitempty"`
+		Line       int                    `json:"line,omitempty"`
+		Function   string                 `json:"function,omitempty"`
+		Stacktrace string                 `json:"stacktrace,omitempty"`
+		TraceID    string                 `json:"trace_id,omitempty"`
+		SpanID     string                 `json:"span_id,omitempty"`
+		Fields     map[string]interface{} `json:"fields,omitempty"`
+		Tags       map[string]string      `json:"tags,omitempty"`
+	}{msg.Event, msg.Hostname, msg.PID, msg.Seq, msg.File, msg.Line, msg.Function, msg.Stacktrace, msg.TraceID, msg.SpanID, msg.Fields, msg.Tags})
+	if err != nil {
+		return err
+	}
+	if len(rest) > 2 {
+		buf.WriteByte(',')
+		buf.Write(rest[1 : len(rest)-1])
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+// writeJSONPair writes "key":value, the value marshaled as JSON.
+func writeJSONPair(buf *bytes.Buffer, key string, value interface{}) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	buf.WriteByte(':')
+	if data, err = json.Marshal(value); err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// LogfmtEncoder encodes messages as logfmt lines, e.g.
+// timestamp=... application=... level=INFO message="some text".
+// Fields and tags follow under the keys "fields.<key>" and "tags.<key>".
+type LogfmtEncoder struct {
+	FieldNames FieldNames // Keys of the timestamp, application, level and message fields.
+}
+
+// Encode implements Encoder.
+func (e LogfmtEncoder) Encode(buf *bytes.Buffer, msg *Message) error {
+	names := e.FieldNames.withDefaults()
+	writeLogfmtPair(buf, names.Timestamp, msg.Timestamp)
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, names.Application, msg.Application)
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, names.Level, msg.Level)
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, names.Message, msg.Message)
+	if msg.Event != "" {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "event", msg.Event)
+	}
+	if msg.Hostname != "" {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "hostname", msg.Hostname)
+	}
+	if msg.PID != 0 {
+		buf.WriteString(" pid=")
+		buf.WriteString(strconv.Itoa(msg.PID))
+	}
+	if msg.Seq != 0 {
+		buf.WriteString(" seq=")
+		buf.WriteString(strconv.FormatUint(msg.Seq, 10))
+	}
+	if msg.File != "" {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "file", msg.File)
+		buf.WriteString(" line=")
+		buf.WriteString(strconv.Itoa(msg.Line))
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "function", msg.Function)
+	}
+	if msg.Stacktrace != "" {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "stacktrace", msg.Stacktrace)
+	}
+	if msg.TraceID != "" {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "trace_id", msg.TraceID)
+	}
+	if msg.SpanID != "" {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "span_id", msg.SpanID)
+	}
+	// Fields and tags are namespaced like their JSON objects, so that they
+	// never clash with the keys above; one still matching a renamed key is
+	// dropped.
+	for _, key := range sortedKeys(msg.Fields) {
+		if name := logfmtKey("fields.", key); !names.has(name) {
+			buf.WriteByte(' ')
+			writeLogfmtPair(buf, name, logfmtValue(msg.Fields[key]))
+		}
+	}
+	for _, key := range sortedKeys(msg.Tags) {
+		if name := logfmtKey("tags.", key); !names.has(name) {
+			buf.WriteByte(' ')
+			writeLogfmtPair(buf, name, msg.Tags[key])
+		}
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// RawEncoder writes only the message text followed by a newline.
+type RawEncoder struct {
+	// KeepNewlines writes newlines and other control characters of the
+	// message as is instead of escaping them, letting one message span
+	// several lines.
+	KeepNewlines bool
+}
+
+// Encode implements Encoder.
+func (e RawEncoder) Encode(buf *bytes.Buffer, msg *Message) error {
+	writeMessageText(buf, msg.Message, e.KeepNewlines)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// writeMessageText writes a message body on a single line: unless keep is
+// set, newlines, carriage returns and other control characters (except tabs)
+// are escaped as \n, \r and \u00XX.
+func writeMessageText(buf *bytes.Buffer, text string, keep bool) {
+	if keep || strings.IndexFunc(text, isEscapedControl) < 0 {
+		buf.WriteString(text)
+		return
+	}
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case isEscapedControl(r):
+			fmt.Fprintf(buf, `\u%04x`, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// isEscapedControl reports whether writeMessageText escapes r.
+func isEscapedControl(r rune) bool {
+	return (r < ' ' && r != '\t') || r == 0x7f
+}
+
+// writeLogfmtPair writes key=value, quoting the value when required.
+func writeLogfmtPair(buf *bytes.Buffer, key string, value string) {
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if logfmtNeedsQuoting(value) {
+		buf.WriteString(strconv.Quote(value))
+		return
+	}
+	buf.WriteString(value)
+}
+
+// logfmtKey returns prefix followed by key, with the characters a logfmt key
+// cannot hold (spaces and other control characters, '=' and '"') replaced by
+// '_'.
+func logfmtKey(prefix string, key string) string {
+	if key == "" {
+		return prefix + "_"
+	}
+	return prefix + strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtNeedsQuoting reports whether a logfmt value has to be quoted.
+func logfmtNeedsQuoting(value string) bool {
+	if value == "" {
+		return true
+	}
+	return strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	}) >= 0
+}
+
+// logfmtValue renders an arbitrary field value for logfmt output.
+func logfmtValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case fmt.Stringer:
+		return value.String()
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// sortedKeys returns the keys of m in lexical order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
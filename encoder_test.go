@@ -0,0 +1,247 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmtEncoderKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  FieldNames
+		fields map[string]interface{}
+		tags   map[string]string
+		want   string
+	}{
+		{
+			name:   "fields and tags are namespaced",
+			fields: map[string]interface{}{"level": "shadow", "count": 2},
+			tags:   map[string]string{"message": "shadow"},
+			want:   "timestamp=t application=app level=INFO message=hello fields.count=2 fields.level=shadow tags.message=shadow\n",
+		},
+		{
+			name:   "invalid key characters are replaced",
+			fields: map[string]interface{}{"a b": 1, `x="y"`: 2, "": 3},
+			want:   "timestamp=t application=app level=INFO message=hello fields._=3 fields.a_b=1 fields.x__y_=2\n",
+		},
+		{
+			name:   "keys matching a renamed key are dropped",
+			names:  FieldNames{Message: "fields.msg"},
+			fields: map[string]interface{}{"msg": "shadow", "other": 1},
+			want:   "timestamp=t application=app level=INFO fields.msg=hello fields.other=1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &Message{Timestamp: "t", Application: "app", Level: "INFO", Message: "hello", Fields: tt.fields, Tags: tt.tags}
+			var buf bytes.Buffer
+			if err := (LogfmtEncoder{FieldNames: tt.names}).Encode(&buf, msg); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// benchMessage returns a message with a few fields, as encoded on the hot path.
+func benchMessage() *Message {
+	return &Message{
+		Timestamp:   "2024-01-02T03:04:05.000Z",
+		Application: "bench",
+		Level:       INFO,
+		Message:     "request served",
+		Fields:      map[string]interface{}{"status": 200, "path": "/api/v1/items"},
+	}
+}
+
+func TestJSONEncoderMatchesMarshal(t *testing.T) {
+	msg := benchMessage()
+	want, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for i := 0; i < 2; i++ { // A fresh buffer, then a recycled one.
+		buf := getBuffer()
+		if err := (JSONEncoder{}).Encode(buf, msg); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if got := buf.String(); got != string(want)+"\n" {
+			t.Errorf("Encode = %q, want %q", got, string(want)+"\n")
+		}
+		putBuffer(buf)
+	}
+}
+
+func BenchmarkJSONMarshal(b *testing.B) {
+	msg := benchMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = append(data, '\n')
+	}
+}
+
+func BenchmarkJSONEncoderPooled(b *testing.B) {
+	msg := benchMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := getBuffer()
+		if err := (JSONEncoder{}).Encode(buf, msg); err != nil {
+			b.Fatal(err)
+		}
+		putBuffer(buf)
+	}
+}
+
+func TestExtraEncodersReceiveEveryFormat(t *testing.T) {
+	server := newTestServer(t)
+	file := &syncBuffer{}
+	clock := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		Clock:         clock,
+		ExtraEncoders: []EncoderSink{{Encoder: LogfmtEncoder{}, Writer: file}},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.InfoFields("hello world", map[string]interface{}{"user": "alice"})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := server.waitForLines(t, 1)
+	if len(lines) != 1 {
+		t.Fatalf("received %d lines, want 1", len(lines))
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(lines[0]), &msg); err != nil {
+		t.Fatalf("network sink did not receive JSON: %v", err)
+	}
+	if msg.Message != "hello world" || msg.Fields["user"] != "alice" {
+		t.Errorf("network sink received %+v", msg)
+	}
+	want := `timestamp=2024-01-02T03:04:05.00Z application=test level=INFO message="hello world" fields.user=alice` + "\n"
+	if got := file.String(); got != want {
+		t.Errorf("file sink received %q, want %q", got, want)
+	}
+}
+
+// parseLogfmt parses a logfmt line as written by LogfmtEncoder.
+func parseLogfmt(t *testing.T, line string) map[string]string {
+	t.Helper()
+	pairs := map[string]string{}
+	for line != "" {
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			t.Fatalf("no value for %q", line)
+		}
+		key, rest := line[:eq], line[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				t.Fatalf("bad quoted value in %q: %v", rest, err)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else if sp := strings.IndexByte(rest, ' '); sp >= 0 {
+			value, rest = rest[:sp], rest[sp:]
+		} else {
+			value, rest = rest, ""
+		}
+		pairs[key] = value
+		line = strings.TrimPrefix(rest, " ")
+	}
+	return pairs
+}
+
+func TestFormatsRoundTrip(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	message := "hello \"quoted\" world\nsecond line"
+	fields := map[string]interface{}{"user": "alice smith", "count": 3}
+
+	t.Run("logfmt", func(t *testing.T) {
+		out := &syncBuffer{}
+		logger, err := New("test", "INFO", "", 0, Options{Writer: out, Format: FormatLogfmt, Clock: clock})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		logger.WithTag("region", "eu").InfoFields(message, fields)
+
+		line := strings.TrimSuffix(out.String(), "\n")
+		if strings.Contains(line, "\n") {
+			t.Fatalf("record spans several lines: %q", line)
+		}
+		want := map[string]string{
+			"timestamp":    "2024-01-02T03:04:05.00Z",
+			"application":  "test",
+			"level":        INFO,
+			"message":      message,
+			"fields.user":  "alice smith",
+			"fields.count": "3",
+			"tags.region":  "eu",
+		}
+		if got := parseLogfmt(t, line); !reflect.DeepEqual(got, want) {
+			t.Errorf("parsed %v, want %v", got, want)
+		}
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		out := &syncBuffer{}
+		logger, err := New("test", "INFO", "", 0, Options{Writer: out, Format: FormatRaw, Clock: clock})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		logger.InfoFields(message, fields)
+		logger.Warn("plain")
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if want := []string{`hello "quoted" world\nsecond line`, "plain"}; !reflect.DeepEqual(lines, want) {
+			t.Errorf("lines = %q, want %q", lines, want)
+		}
+	})
+}
+
+func TestFieldNamesOnTheWire(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		FieldNames: FieldNames{Timestamp: "@timestamp", Message: "msg"},
+		Clock:      func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.InfoFields("renamed", map[string]interface{}{"msg": "user field"})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := server.waitForLines(t, 1)
+	if len(lines) != 1 {
+		t.Fatalf("received %d lines, want 1", len(lines))
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("cannot decode %q: %v", lines[0], err)
+	}
+	want := map[string]interface{}{
+		"@timestamp":  "2024-01-02T03:04:05.00Z",
+		"application": "test",
+		"level":       INFO,
+		"msg":         "renamed",
+		"fields":      map[string]interface{}{"msg": "user field"},
+	}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("received %v, want %v", record, want)
+	}
+}
@@ -0,0 +1,37 @@
+package go_vector_logger
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errorFielder is implemented by errors carrying structured fields, which
+// are recorded in the error chain, see Options.UnwrapErrors.
+type errorFielder interface {
+	Fields() map[string]interface{}
+}
+
+// errorFields returns the fields describing err: its text under "error" and,
+// with Options.UnwrapErrors, every layer of its chain under "error_chain".
+func (l *VectorLogger) errorFields(err error) map[string]interface{} {
+	fields := map[string]interface{}{"error": err.Error()}
+	if !l.Options.UnwrapErrors {
+		return fields
+	}
+
+	var chain []interface{}
+	for layer := err; layer != nil; layer = errors.Unwrap(layer) {
+		entry := map[string]interface{}{
+			"message": layer.Error(),
+			"type":    fmt.Sprintf("%T", layer),
+		}
+		if fielder, ok := layer.(errorFielder); ok {
+			if layerFields := fielder.Fields(); len(layerFields) > 0 {
+				entry["fields"] = layerFields
+			}
+		}
+		chain = append(chain, entry)
+	}
+	fields["error_chain"] = chain
+	return fields
+}
@@ -0,0 +1,64 @@
+package go_vector_logger
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLogErr(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if got := logger.LogErr(nil); got != nil {
+		t.Errorf("LogErr(nil) = %v", got)
+	}
+	if got := out.String(); got != "" {
+		t.Fatalf("LogErr(nil) emitted %q", got)
+	}
+
+	boom := errors.New("boom")
+	if got := logger.LogErr(boom); got != boom {
+		t.Errorf("LogErr returned %v, want the error passed", got)
+	}
+	records := decodeLines(t, out.String())
+	if len(records) != 1 {
+		t.Fatalf("emitted %d records, want 1", len(records))
+	}
+	fields, _ := records[0]["fields"].(map[string]interface{})
+	if records[0]["level"] != ERROR || records[0]["message"] != "boom" || fields["error"] != "boom" {
+		t.Errorf("emitted %v", records[0])
+	}
+}
+
+// fieldsError is an error carrying structured fields.
+type fieldsError struct{ id int }
+
+func (e fieldsError) Error() string { return fmt.Sprintf("record %d not found", e.id) }
+
+func (e fieldsError) Fields() map[string]interface{} {
+	return map[string]interface{}{"id": e.id}
+}
+
+func TestUnwrapErrorsRecordsChain(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, UnwrapErrors: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.ErrorErr(fmt.Errorf("handling request: %w", fmt.Errorf("loading user: %w", fieldsError{id: 7})))
+
+	fields, _ := decodeLines(t, out.String())[0]["fields"].(map[string]interface{})
+	want := []interface{}{
+		map[string]interface{}{"message": "handling request: loading user: record 7 not found", "type": "*fmt.wrapError"},
+		map[string]interface{}{"message": "loading user: record 7 not found", "type": "*fmt.wrapError"},
+		map[string]interface{}{"message": "record 7 not found", "type": "go_vector_logger.fieldsError", "fields": map[string]interface{}{"id": 7.0}},
+	}
+	if got := fields["error_chain"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("error_chain = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,33 @@
+package go_vector_logger
+
+import "fmt"
+
+// Event logs the semantic event name at level, e.g. "user.signup", with
+// attrs as its structured fields, so that Vector can aggregate messages by
+// event rather than by free text. The name is emitted under "event" and as
+// the message. A FATAL event is logged like any other and does not exit. An
+// event at an unknown level is dropped and reported like internal errors.
+func (l *VectorLogger) Event(level, name string, attrs map[string]interface{}) {
+	level, err := parseLevel(level, l.Options.LevelAliases)
+	if err != nil {
+		l.reportError(fmt.Errorf("cannot log event %q: %w", name, err))
+		return
+	}
+	if !l.shouldLog(level) {
+		return
+	}
+	l.withFields(attrs).sendEvent(name, level)
+}
+
+// sendEvent sends the event name at level, like sendMessage.
+func (l *VectorLogger) sendEvent(name string, level string) {
+	if !l.hasDestination() || !l.admit(level) {
+		return
+	}
+	msg := l.newMessage(name, level)
+	msg.Event = name
+	if l.dedup(msg) {
+		return
+	}
+	l.send(msg)
+}
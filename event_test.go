@@ -0,0 +1,27 @@
+package go_vector_logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvent(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Event("info", "user_signup", map[string]interface{}{"plan": "pro", "seats": 3})
+	logger.Event(DEBUG, "filtered", nil)
+
+	records := decodeLines(t, out.String())
+	if len(records) != 1 {
+		t.Fatalf("emitted %d records, want 1", len(records))
+	}
+	if records[0]["event"] != "user_signup" || records[0]["level"] != INFO {
+		t.Errorf("record = %v", records[0])
+	}
+	if got, want := records[0]["fields"], map[string]interface{}{"plan": "pro", "seats": 3.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
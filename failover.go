@@ -0,0 +1,81 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultFailbackInterval is how long messages go to Options.Fallback before
+// the primary Vector is tried again.
+const defaultFailbackInterval = 5 * time.Second
+
+// failbackInterval returns how long a failover lasts before the primary is
+// probed again.
+func (l *VectorLogger) failbackInterval() time.Duration {
+	if l.Options.FailbackInterval > 0 {
+		return l.Options.FailbackInterval
+	}
+	return defaultFailbackInterval
+}
+
+// failingOver reports whether writes currently go to Options.Fallback
+// without trying the primary. The caller must hold l.mu.
+func (l *VectorLogger) failingOver() bool {
+	return l.Options.Fallback != nil && time.Now().Before(l.failoverUntil)
+}
+
+// failOver starts or extends a failover after the primary could not be
+// reached because of err, and writes data to the fallback. The caller must
+// hold l.mu.
+func (l *VectorLogger) failOver(data []byte, messages int, level string, deadline time.Time, err error) error {
+	if l.failoverUntil.IsZero() {
+		l.deferError(fmt.Errorf("sending logs to the fallback: %w", err))
+	}
+	l.failoverUntil = time.Now().Add(l.failbackInterval())
+	return l.writeToFallback(data, messages, level, deadline)
+}
+
+// failBack ends a failover once the primary is reachable again. The caller
+// must hold l.mu.
+func (l *VectorLogger) failBack() {
+	if !l.failoverUntil.IsZero() {
+		l.deferDebugf("vector on %s is reachable again, leaving the fallback", l.address())
+	}
+	l.failoverUntil = time.Time{}
+}
+
+// writeToFallback writes data, encoded for l, to Options.Fallback. What the
+// fallback records for its unlock, errors, diagnostics and failed messages,
+// is handed over to l's, since the caller must hold l.mu.
+func (l *VectorLogger) writeToFallback(data []byte, messages int, level string, deadline time.Time) error {
+	fallback := l.Options.Fallback
+	if fallback.core == nil {
+		return fmt.Errorf("cannot send logs to the fallback: logger not created by New")
+	}
+
+	fallback.mu.Lock()
+	var err error
+	if writer := fallback.writer(); writer != nil {
+		if _, err = writer.Write(data); err == nil {
+			fallback.stats.sent.Add(uint64(messages))
+		}
+	} else {
+		err = fallback.writeToConn(data, messages, level, deadline)
+	}
+	errs, debugLines, failures := fallback.errs, fallback.debugLines, fallback.failures
+	fallback.errs, fallback.debugLines, fallback.failures = nil, nil, nil
+	fallback.mu.Unlock()
+
+	l.errs = append(l.errs, errs...)
+	l.debugLines = append(l.debugLines, debugLines...)
+	for _, f := range failures {
+		if f.logger == nil {
+			f.logger = fallback
+		}
+		l.failures = append(l.failures, f)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot send logs to the fallback: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailoverAndFailback(t *testing.T) {
+	backup := newTestServer(t)
+	fallback, err := New("test", "INFO", "127.0.0.1", backup.port())
+	if err != nil {
+		t.Fatalf("New fallback: %v", err)
+	}
+	defer fallback.Close()
+
+	port := closedPort(t)
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{
+		LazyConnect:         true,
+		Fallback:            fallback,
+		FailbackInterval:    50 * time.Millisecond,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("primary down")
+	logger.Info("still down")
+	if lines := backup.waitForLines(t, 2); countContaining(lines, "down") != 2 {
+		t.Fatalf("fallback received %q", lines)
+	}
+
+	primary := newTestServerOn(t, port)
+	time.Sleep(60 * time.Millisecond) // Let the failover end.
+	logger.Info("primary back")
+	if lines := primary.waitForLines(t, 1); countContaining(lines, "primary back") != 1 {
+		t.Errorf("primary received %q after failing back", lines)
+	}
+	if got := len(backup.received()); got != 2 {
+		t.Errorf("fallback received %d lines, want 2", got)
+	}
+}
+
+func TestFallbackDiagnosticsAreReported(t *testing.T) {
+	backup := newTestServer(t)
+	internal := &syncBuffer{}
+	fallback, err := New("test", "INFO", "127.0.0.1", backup.port(), Options{
+		LazyConnect:         true,
+		Debug:               true,
+		InternalErrorWriter: internal,
+	})
+	if err != nil {
+		t.Fatalf("New fallback: %v", err)
+	}
+	defer fallback.Close()
+
+	logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{
+		LazyConnect:         true,
+		Fallback:            fallback,
+		Debug:               true,
+		InternalErrorWriter: internal,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("primary down")
+	backup.waitForLines(t, 1)
+
+	fallback.mu.Lock()
+	pending := len(fallback.debugLines) + len(fallback.errs) + len(fallback.failures)
+	fallback.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("fallback kept %d records of the write for its own unlock", pending)
+	}
+	if !strings.Contains(internal.String(), fmt.Sprintf("connected to vector on 127.0.0.1:%d", backup.port())) {
+		t.Errorf("internal output = %q, want the connection of the fallback", internal.String())
+	}
+}
@@ -0,0 +1,100 @@
+package go_vector_logger
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// flattenFields returns fields with the nested maps replaced by their entries
+// under dotted keys, e.g. {"http": {"status": 200}} becomes
+// {"http.status": 200}, see Options.FlattenFields. With slices, slices and
+// arrays are flattened too, under their indexes ("tags.0"); otherwise they
+// are kept as arrays.
+//
+// When several values end up under the same key, e.g. {"http.status": 200}
+// and {"http": {"status": 500}}, the one nested the least wins, here 200;
+// between equally nested values, the first in key order wins.
+func flattenFields(fields map[string]interface{}, slices bool) map[string]interface{} {
+	if !needsFlattening(fields, slices) {
+		return fields
+	}
+	flat := make(map[string]interface{}, len(fields))
+	pending := []flattenEntry{{value: fields}}
+	for len(pending) > 0 {
+		var next []flattenEntry
+		for _, entry := range pending {
+			next = flattenLevel(flat, next, entry, slices)
+		}
+		pending = next
+	}
+	return flat
+}
+
+// flattenEntry is a map or slice left to flatten under prefix.
+type flattenEntry struct {
+	prefix string
+	value  interface{}
+}
+
+// needsFlattening reports whether fields holds a value flattenFields expands.
+func needsFlattening(fields map[string]interface{}, slices bool) bool {
+	for _, v := range fields {
+		if _, ok := v.(map[string]interface{}); ok {
+			return true
+		}
+		if slices && isFlattenedSlice(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenLevel stores the values held by entry in flat, in key order, unless
+// a key is already taken, and appends the nested maps and slices it expands
+// to next, so that they are stored once the whole level is. Empty maps and
+// slices are kept as they are, so that the key does not disappear.
+func flattenLevel(flat map[string]interface{}, next []flattenEntry, entry flattenEntry, slices bool) []flattenEntry {
+	join := func(key string) string {
+		if entry.prefix == "" {
+			return key
+		}
+		return entry.prefix + "." + key
+	}
+	store := func(key string, v interface{}) []flattenEntry {
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			return append(next, flattenEntry{prefix: key, value: m})
+		}
+		if slices && isFlattenedSlice(v) && reflect.ValueOf(v).Len() > 0 {
+			return append(next, flattenEntry{prefix: key, value: v})
+		}
+		if _, taken := flat[key]; !taken {
+			flat[key] = v
+		}
+		return next
+	}
+
+	if m, ok := entry.value.(map[string]interface{}); ok {
+		for _, k := range sortedKeys(m) {
+			next = store(join(k), m[k])
+		}
+		return next
+	}
+	rv := reflect.ValueOf(entry.value)
+	for i := 0; i < rv.Len(); i++ {
+		next = store(join(strconv.Itoa(i)), rv.Index(i).Interface())
+	}
+	return next
+}
+
+// isFlattenedSlice reports whether v is a slice or an array that flattening
+// expands; byte slices are kept whole, as JSON encodes them as one string.
+func isFlattenedSlice(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	switch t := reflect.TypeOf(v); t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package go_vector_logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		slices bool
+		want   map[string]interface{}
+	}{
+		{
+			name:   "nested maps",
+			fields: map[string]interface{}{"http": map[string]interface{}{"status": 200, "req": map[string]interface{}{"path": "/"}}},
+			want:   map[string]interface{}{"http.status": 200, "http.req.path": "/"},
+		},
+		{
+			name:   "slices kept",
+			fields: map[string]interface{}{"tags": []string{"a", "b"}},
+			want:   map[string]interface{}{"tags": []string{"a", "b"}},
+		},
+		{
+			name:   "slices flattened",
+			fields: map[string]interface{}{"tags": []string{"a", "b"}, "raw": []byte("x"), "none": []int{}},
+			slices: true,
+			want:   map[string]interface{}{"tags.0": "a", "tags.1": "b", "raw": []byte("x"), "none": []int{}},
+		},
+		{
+			name: "literal dotted key wins over nested path",
+			fields: map[string]interface{}{
+				"http.status": 200,
+				"http":        map[string]interface{}{"status": 500, "method": "GET"},
+			},
+			want: map[string]interface{}{"http.status": 200, "http.method": "GET"},
+		},
+		{
+			name: "least nested wins at any depth",
+			fields: map[string]interface{}{
+				"a": map[string]interface{}{
+					"b":   map[string]interface{}{"c": "deep"},
+					"b.c": "shallow",
+				},
+			},
+			want: map[string]interface{}{"a.b.c": "shallow"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				if got := flattenFields(tt.fields, tt.slices); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Framing selects how records are delimited on the wire, see Options.Framing.
+type Framing int
+
+const (
+	// FramingNewline ends every record with a newline (default), matching the
+	// newline_delimited framing of Vector's socket source.
+	FramingNewline Framing = iota
+	// FramingLengthPrefix precedes every record, without its trailing
+	// newline, with its length as a big-endian uint32, matching the
+	// length_delimited framing of Vector's socket source.
+	FramingLengthPrefix
+)
+
+// lengthPrefixSize is the size of the length written before every record
+// with FramingLengthPrefix.
+const lengthPrefixSize = 4
+
+// validateFraming checks Options.Framing against the protocol.
+func validateFraming(opts *Options) error {
+	switch opts.Framing {
+	case FramingNewline:
+		return nil
+	case FramingLengthPrefix:
+		if opts.Protocol == "http" {
+			return fmt.Errorf("length-prefixed framing is not supported with the http protocol")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported framing %d, expected FramingNewline or FramingLengthPrefix", opts.Framing)
+	}
+}
+
+// encode appends msg to buf in the wire format: the record built by
+// encodeRecord, framed as selected by Options.Framing.
+func (l *VectorLogger) encode(buf *bytes.Buffer, msg *Message) error {
+	if l.Options.Framing != FramingLengthPrefix {
+		return l.encodeRecord(buf, msg)
+	}
+
+	start := buf.Len()
+	buf.Write(make([]byte, lengthPrefixSize))
+	if err := l.encodeRecord(buf, msg); err != nil {
+		return err
+	}
+	record := bytes.TrimSuffix(buf.Bytes()[start+lengthPrefixSize:], []byte{'\n'})
+	buf.Truncate(start + lengthPrefixSize + len(record))
+	binary.BigEndian.PutUint32(buf.Bytes()[start:], uint32(len(record)))
+	return nil
+}
+
+// recordsEnd returns the end of the last record of data held whole in its
+// first n bytes.
+func (l *VectorLogger) recordsEnd(data []byte, n int) int {
+	if l.Options.Framing != FramingLengthPrefix {
+		return bytes.LastIndexByte(data[:n], '\n') + 1
+	}
+
+	end := 0
+	for end+lengthPrefixSize <= n {
+		next := end + lengthPrefixSize + int(binary.BigEndian.Uint32(data[end:]))
+		if next > n {
+			break
+		}
+		end = next
+	}
+	return end
+}
+
+// countRecords returns the number of records held in data, which ends at a
+// record boundary.
+func (l *VectorLogger) countRecords(data []byte) int {
+	if l.Options.Framing != FramingLengthPrefix {
+		return bytes.Count(data, []byte{'\n'})
+	}
+
+	count := 0
+	for end := 0; end+lengthPrefixSize <= len(data); count++ {
+		end += lengthPrefixSize + int(binary.BigEndian.Uint32(data[end:]))
+	}
+	return count
+}
@@ -0,0 +1,106 @@
+package go_vector_logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFrames decodes the records of framing read from conn until it closes.
+// It runs on its own goroutine, so it reports errors without stopping the
+// test.
+func readFrames(t *testing.T, conn net.Conn, framing Framing) []Message {
+	var records [][]byte
+	reader := bufio.NewReader(conn)
+	for {
+		var record []byte
+		var err error
+		if framing == FramingLengthPrefix {
+			var size uint32
+			if err = binary.Read(reader, binary.BigEndian, &size); err == nil {
+				record = make([]byte, size)
+				_, err = io.ReadFull(reader, record)
+			}
+		} else {
+			record, err = reader.ReadBytes('\n')
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Errorf("cannot read a frame: %v", err)
+			break
+		}
+		records = append(records, record)
+	}
+
+	var messages []Message
+	for _, record := range records {
+		var msg Message
+		if err := json.Unmarshal(record, &msg); err != nil {
+			t.Errorf("cannot decode %q: %v", record, err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestFraming(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		framing Framing
+		batch   int
+	}{
+		{"newline", FramingNewline, 0},
+		{"newline batched", FramingNewline, 3},
+		{"length prefix", FramingLengthPrefix, 0},
+		{"length prefix batched", FramingLengthPrefix, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			received := make(chan []Message, 1)
+			go func() { received <- readFrames(t, server, tc.framing) }()
+
+			logger, err := New("test", "INFO", "", 0, Options{
+				Conn:          client,
+				Framing:       tc.framing,
+				BatchSize:     tc.batch,
+				BatchInterval: time.Hour,
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logger.Info("first")
+			logger.Info("multi\nline")
+			logger.Info("third")
+			if err := logger.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			messages := <-received
+			var got []string
+			for _, msg := range messages {
+				got = append(got, msg.Message)
+			}
+			if want := "first|multi\nline|third"; strings.Join(got, "|") != want {
+				t.Errorf("received %q, want %q", got, strings.Split(want, "|"))
+			}
+		})
+	}
+}
+
+func TestFramingValidation(t *testing.T) {
+	if _, err := New("test", "INFO", "", 0, Options{Writer: io.Discard, Framing: Framing(7)}); err == nil {
+		t.Error("New accepted an unknown framing")
+	}
+	_, err := New("test", "INFO", "", 0, Options{Protocol: "http", HTTPEndpoint: "http://127.0.0.1:1", Framing: FramingLengthPrefix})
+	if err == nil {
+		t.Error("New accepted length-prefixed framing over http")
+	}
+}
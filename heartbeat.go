@@ -0,0 +1,51 @@
+package go_vector_logger
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// heartbeatReadTimeout is how long a heartbeat waits for the peer to close
+// the connection.
+const heartbeatReadTimeout = time.Millisecond
+
+// heartbeatTicker returns the channel of the heartbeat ticks, nil when
+// Options.Heartbeat is not set or the protocol has no connection to probe.
+func (l *VectorLogger) heartbeatTicker() (<-chan time.Time, func()) {
+	if l.Options.Heartbeat <= 0 || l.network() != "tcp" {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(l.Options.Heartbeat)
+	return ticker.C, ticker.Stop
+}
+
+// heartbeat closes the connection when the peer is gone and reconnects right
+// away, rather than on the next message. The caller must hold l.mu.
+func (l *VectorLogger) heartbeat() {
+	if l.conn == nil || l.connectionAlive() {
+		return
+	}
+	l.deferDebugf("connection to vector on %s lost, reconnecting", l.address())
+	l.loseConnection()
+	if err := l.reconnect(); err != nil {
+		l.deferError(err)
+	}
+}
+
+// connectionAlive reports whether the peer still holds the connection.
+// Vector never writes on it, so a short read ending with anything but a
+// timeout means that the peer closed it or that it was reset, e.g. by TCP
+// keep-alive. The caller must hold l.mu and l.conn must be set.
+func (l *VectorLogger) connectionAlive() bool {
+	conn := l.conn
+	if err := conn.SetReadDeadline(time.Now().Add(heartbeatReadTimeout)); err != nil {
+		return false
+	}
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	var buf [64]byte
+	_, err := conn.Read(buf[:])
+	var netErr net.Error
+	return err == nil || (errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,128 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultHTTPTimeout bounds a POST to Vector when Options.HTTPClient is not set.
+const defaultHTTPTimeout = 10 * time.Second
+
+// defaultHTTPClient posts to Vector when Options.HTTPClient is not set.
+var defaultHTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+
+// usesHTTP reports whether messages are posted to a Vector http source.
+func (l *VectorLogger) usesHTTP() bool {
+	return l.Options.Protocol == "http"
+}
+
+// httpEndpoint returns the URL messages are posted to.
+func (l *VectorLogger) httpEndpoint() string {
+	if l.Options.HTTPEndpoint != "" {
+		return l.Options.HTTPEndpoint
+	}
+	return "http://" + l.address() + "/"
+}
+
+// httpClient returns the client used to post to Vector.
+func (l *VectorLogger) httpClient() *http.Client {
+	if l.Options.HTTPClient != nil {
+		return l.Options.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+// writeHTTP posts data holding the given number of messages to Vector,
+// after the content of the offline buffer. Failed posts go to the offline
+// buffer when it is enabled. The caller must hold l.mu.
+func (l *VectorLogger) writeHTTP(data []byte, messages int, level string, deadline time.Time) error {
+	err := l.flushOffline()
+	if err == nil {
+		err = l.post(data, messages, deadline)
+	}
+	if err != nil {
+		if l.keepOffline(err) {
+			l.bufferOffline(data, messages, level)
+			return nil
+		}
+		return err
+	}
+
+	l.lastActivityTime = time.Now()
+	l.stats.sent.Add(uint64(messages))
+	l.setLastError(nil)
+	return nil
+}
+
+// post sends one request holding data to Vector. A non-zero deadline, and
+// Options.WriteTimeout when set, bound the request; responses other than 2xx
+// are errors. The caller must hold l.mu.
+func (l *VectorLogger) post(data []byte, messages int, deadline time.Time) error {
+	if timeout := l.Options.WriteTimeout; timeout > 0 {
+		if timeoutDeadline := time.Now().Add(timeout); deadline.IsZero() || timeoutDeadline.Before(deadline) {
+			deadline = timeoutDeadline
+		}
+	}
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	body, contentType := l.httpBody(data, messages)
+	if l.compressing() {
+		compressed, err := gzipBody(body)
+		if err != nil {
+			return fmt.Errorf("cannot compress data for vector: %w", err)
+		}
+		body = compressed
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.httpEndpoint(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot send data to vector: %w", err)
+	}
+	for key, values := range l.Options.HTTPHeaders {
+		req.Header[key] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if l.compressing() {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := l.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send data to vector: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cannot send data to vector on %s: %s", l.httpEndpoint(), resp.Status)
+	}
+	return nil
+}
+
+// httpBody returns the request body for data holding the given number of
+// encoded messages, and its content type. JSON messages are posted as one
+// object, or as an array for a batch; other formats as newline-delimited text.
+func (l *VectorLogger) httpBody(data []byte, messages int) ([]byte, string) {
+	if l.Options.Format != "" && l.Options.Format != FormatJSON {
+		return data, "text/plain; charset=utf-8"
+	}
+	if messages <= 1 {
+		return data, "application/json"
+	}
+
+	records := bytes.Split(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'})
+	body := make([]byte, 0, len(data)+2)
+	body = append(body, '[')
+	body = append(body, bytes.Join(records, []byte{','})...)
+	body = append(body, ']', '\n')
+	return body, "application/json"
+}
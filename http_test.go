@@ -0,0 +1,123 @@
+package go_vector_logger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpRequest is a request received by an httpSink.
+type httpRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// httpSink is an httptest.Server standing in for a Vector http source: it
+// records every request and answers with status.
+type httpSink struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []httpRequest
+}
+
+// newHTTPSink starts an httpSink, closed at the end of the test.
+func newHTTPSink(t *testing.T) *httpSink {
+	t.Helper()
+	s := &httpSink{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, httpRequest{header: r.Header.Clone(), body: body})
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// setStatus changes the status of the next responses.
+func (s *httpSink) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// received returns the requests received so far.
+func (s *httpSink) received() []httpRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]httpRequest(nil), s.requests...)
+}
+
+func TestHTTPTransport(t *testing.T) {
+	sink := newHTTPSink(t)
+	logger, err := New("test", "INFO", "", 0, Options{
+		Protocol:            "http",
+		HTTPEndpoint:        sink.URL + "/logs",
+		HTTPHeaders:         http.Header{"Authorization": {"Bearer token"}},
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	if err := logger.TryInfo("posted"); err != nil {
+		t.Fatalf("TryInfo: %v", err)
+	}
+	sink.setStatus(http.StatusServiceUnavailable)
+	if err := logger.TryInfo("rejected"); err == nil {
+		t.Error("TryInfo succeeded on a 503 response")
+	}
+
+	requests := sink.received()
+	if len(requests) != 2 {
+		t.Fatalf("received %d requests, want 2", len(requests))
+	}
+	var msg Message
+	if err := json.Unmarshal(requests[0].body, &msg); err != nil || msg.Message != "posted" {
+		t.Errorf("body = %q (%v)", requests[0].body, err)
+	}
+	if got := requests[0].header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := requests[0].header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if stats := logger.Stats(); stats.Sent != 1 || stats.Failed != 1 {
+		t.Errorf("Sent = %d, Failed = %d, want 1 and 1", stats.Sent, stats.Failed)
+	}
+}
+
+func TestHTTPTransportPostsBatchesAsArrays(t *testing.T) {
+	sink := newHTTPSink(t)
+	logger, err := New("test", "INFO", "", 0, Options{
+		Protocol:      "http",
+		HTTPEndpoint:  sink.URL,
+		BatchSize:     3,
+		BatchInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		logger.Infof("message %d", i)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	requests := sink.received()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	var batch []Message
+	if err := json.Unmarshal(requests[0].body, &batch); err != nil || len(batch) != 3 {
+		t.Errorf("body = %q (%v), want an array of 3 messages", requests[0].body, err)
+	}
+}
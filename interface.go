@@ -0,0 +1,38 @@
+package go_vector_logger
+
+// Logger is the set of logging methods of VectorLogger, so that code can
+// accept a logger it does not create, e.g. a fake in tests. New keeps
+// returning *VectorLogger; MultiLogger satisfies Logger too.
+type Logger interface {
+	Trace(message string)
+	Tracef(format string, v ...interface{})
+	Debug(message string)
+	Debugf(format string, v ...interface{})
+	Info(message string)
+	Infof(format string, v ...interface{})
+	Warn(message string)
+	Warnf(format string, v ...interface{})
+	Error(message string)
+	Errorf(format string, v ...interface{})
+
+	DebugFields(message string, fields map[string]interface{})
+	InfoFields(message string, fields map[string]interface{})
+	WarnFields(message string, fields map[string]interface{})
+	ErrorFields(message string, fields map[string]interface{})
+
+	LogErr(err error) error
+	ErrorErr(err error)
+	Event(level, name string, attrs map[string]interface{})
+
+	Fatal(message string)
+	Fatalf(format string, v ...interface{})
+	FatalError(message error)
+
+	Flush() error
+	Close() error
+}
+
+var (
+	_ Logger = (*VectorLogger)(nil)
+	_ Logger = (*MultiLogger)(nil)
+)
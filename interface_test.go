@@ -0,0 +1,44 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fakeLogger records the messages logged at INFO; it embeds Logger for the
+// methods it does not need, as a consumer's fake would.
+type fakeLogger struct {
+	Logger
+	infos []string
+}
+
+func (f *fakeLogger) Info(message string) { f.infos = append(f.infos, message) }
+
+func (f *fakeLogger) Infof(format string, v ...interface{}) {
+	f.Info(fmt.Sprintf(format, v...))
+}
+
+// handleOrder stands in for consumer code that accepts any Logger.
+func handleOrder(logger Logger, id int) {
+	logger.Infof("order %d handled", id)
+}
+
+func TestLoggerInterface(t *testing.T) {
+	fake := &fakeLogger{}
+	handleOrder(fake, 1)
+	handleOrder(fake, 2)
+	if want := []string{"order 1 handled", "order 2 handled"}; !reflect.DeepEqual(fake.infos, want) {
+		t.Errorf("fake recorded %q, want %q", fake.infos, want)
+	}
+
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	handleOrder(logger, 3)
+	if records := decodeLines(t, out.String()); len(records) != 1 || records[0]["message"] != "order 3 handled" {
+		t.Errorf("VectorLogger emitted %v", records)
+	}
+}
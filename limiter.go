@@ -0,0 +1,55 @@
+package go_vector_logger
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket refilled at Options.RateLimit tokens per
+// second, holding at most one second worth of tokens.
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket, reporting false when it is empty.
+func (r *rateLimiter) allow(rate int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.last.IsZero() {
+		r.tokens = float64(rate)
+	} else {
+		r.tokens += now.Sub(r.last).Seconds() * float64(rate)
+		if r.tokens > float64(rate) {
+			r.tokens = float64(rate)
+		}
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// admit applies sampling and rate limiting to a message at level, counting
+// the messages it rejects as dropped. Fatal messages are always admitted.
+func (l *VectorLogger) admit(level string) bool {
+	if level == FATAL || l.core == nil {
+		return true
+	}
+	if rate := l.Options.SampleRate; rate > 0 && rate < 1 && rand.Float64() >= rate {
+		l.stats.dropped.Add(1)
+		return false
+	}
+	if l.Options.RateLimit > 0 && !l.limiter.allow(l.Options.RateLimit) {
+		l.stats.dropped.Add(1)
+		return false
+	}
+	return true
+}
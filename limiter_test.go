@@ -0,0 +1,48 @@
+package go_vector_logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitAndSampling(t *testing.T) {
+	const logged = 10000
+	tests := []struct {
+		name     string
+		options  Options
+		min, max int // The rate limit refills the bucket while logging too.
+	}{
+		{"rate limit", Options{RateLimit: 100}, 100, 100},
+		{"sample rate", Options{SampleRate: 0.1}, 700, 1300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &syncBuffer{}
+			opts := tt.options
+			opts.Writer = out
+			opts.NoExit = true
+			logger, err := New("test", "INFO", "", 0, opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			start := time.Now()
+			for i := 0; i < logged; i++ {
+				logger.Info("flood")
+			}
+			refilled := int(time.Since(start).Seconds()*float64(opts.RateLimit)) + 1
+			logger.Fatal("fatal")
+
+			delivered := strings.Count(out.String(), `"message":"flood"`)
+			if max := tt.max + refilled; delivered < tt.min || delivered > max {
+				t.Errorf("delivered %d of %d messages, want between %d and %d", delivered, logged, tt.min, max)
+			}
+			if dropped := logger.Stats().Dropped; dropped != uint64(logged-delivered) {
+				t.Errorf("Dropped = %d, want %d", dropped, logged-delivered)
+			}
+			if !strings.Contains(out.String(), `"message":"fatal"`) {
+				t.Error("fatal message was rejected")
+			}
+		})
+	}
+}
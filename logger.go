@@ -3,36 +3,498 @@ package go_vector_logger
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
-	DEBUG string = "DEBUG"
+	TRACE string = "TRACE"
+	DEBUG        = "DEBUG"
 	INFO         = "INFO"
 	WARN         = "WARN"
 	ERROR        = "ERROR"
 	FATAL        = "FATAL"
 )
 
+// defaultTimestampFormat is the layout of the timestamp field unless
+// Options.TimestampFormat is set.
+const defaultTimestampFormat = "2006-01-02T15:04:05.00Z"
+
+// levelSeverity orders the levels from the least to the most severe.
+var levelSeverity = map[string]int{
+	TRACE: -1,
+	DEBUG: 0,
+	INFO:  1,
+	WARN:  2,
+	ERROR: 3,
+	FATAL: 4,
+}
+
+// levelAliases maps the other common names of levels to the level constants.
+var levelAliases = map[string]string{
+	"WARNING":  WARN,
+	"ERR":      ERROR,
+	"CRITICAL": FATAL,
+}
+
+// ParseLevel validates a level name, case-insensitively, and returns the
+// matching level constant. WARNING, ERR and CRITICAL are accepted for WARN,
+// ERROR and FATAL.
+func ParseLevel(level string) (string, error) {
+	return parseLevel(level, nil)
+}
+
+// parseLevel is ParseLevel, trying aliases, keyed by upper-case name, before
+// the built-in ones.
+func parseLevel(level string, aliases map[string]string) (string, error) {
+	upper := strings.ToUpper(strings.TrimSpace(level))
+	if alias, ok := aliases[upper]; ok {
+		upper = alias
+	} else if alias, ok := levelAliases[upper]; ok {
+		upper = alias
+	}
+	if _, ok := levelSeverity[upper]; !ok {
+		return "", fmt.Errorf("unknown log level %q, expected one of TRACE, DEBUG, INFO, WARN, ERROR, FATAL", level)
+	}
+	return upper, nil
+}
+
+// normalizeLevelAliases returns aliases keyed by upper-case name, each
+// resolved to a level constant.
+func normalizeLevelAliases(aliases map[string]string) (map[string]string, error) {
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(aliases))
+	for alias, level := range aliases {
+		parsed, err := ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level alias %q: %w", alias, err)
+		}
+		normalized[strings.ToUpper(strings.TrimSpace(alias))] = parsed
+	}
+	return normalized, nil
+}
+
+// streamIDs maps levels to the header byte written when Options.StreamHeader is set.
+var streamIDs = map[string]byte{
+	DEBUG: 1,
+	INFO:  2,
+	WARN:  3,
+	ERROR: 4,
+	FATAL: 5,
+	TRACE: 6,
+}
+
+// StreamID returns the stream header byte used for level, or 0 for an unknown level.
+func StreamID(level string) byte {
+	return streamIDs[level]
+}
+
+// LevelForStreamID maps a stream header byte back to its level. It returns an
+// empty string for unknown identifiers.
+func LevelForStreamID(id byte) string {
+	for level, levelID := range streamIDs {
+		if levelID == id {
+			return level
+		}
+	}
+	return ""
+}
+
 // Options list different options you can optionally pass into New
 type Options struct {
 	Writer            io.Writer // Instead of over the network, write the log messages just to this writer
 	AlsoPrintMessages bool      // In addition to the specific network, also log any messages to stdout
+
+	// ConsoleFormat returns the line printed for a message with
+	// AlsoPrintMessages, without the trailing newline. It defaults to
+	// "timestamp | level | message".
+	ConsoleFormat func(msg *Message) string
+
+	// ConsoleWriter receives the printed lines instead of stdout, e.g.
+	// os.Stderr for services using stdout for their own protocol. Setting it
+	// enables printing even without AlsoPrintMessages. Writes to it are
+	// serialized.
+	ConsoleWriter io.Writer
+
+	// MirrorToStderrLevel prints the messages at that level or above, e.g.
+	// WARN, to stderr in the console format, as a local record should Vector
+	// be unreachable. Messages already printed with AlsoPrintMessages or
+	// ConsoleWriter are not printed a second time. Empty by default.
+	MirrorToStderrLevel string
+
+	// Color wraps the level of printed messages in ANSI colors (red for
+	// ERROR, yellow for WARN, ...) when the console is a terminal. ForceColor
+	// colorizes them even when it is not.
+	Color      bool
+	ForceColor bool
+
+	// ExtraEncoders receive every message in addition to the main destination,
+	// each encoded with its own Encoder (e.g. logfmt to a file while JSON goes
+	// over the network).
+	ExtraEncoders []EncoderSink
+
+	// StreamHeader prefixes every record sent to Vector with a single byte
+	// identifying its level (see StreamID), so one socket can carry several
+	// distinguishable streams.
+	StreamHeader bool
+
+	// Framing selects how records are delimited on the wire: FramingNewline
+	// (default) or FramingLengthPrefix, to match the framing of Vector's
+	// socket source. It applies to Writer too, and applies per message in a
+	// batch. It is not supported over HTTP.
+	Framing Framing
+
+	// IncludeCaller adds the file, line and function of the log call to every
+	// message. Call sites are resolved once per program counter and cached.
+	IncludeCaller bool
+
+	// LevelAliases maps extra level names, case-insensitively, to levels,
+	// e.g. "VERBOSE" to DEBUG or "NOTICE" to INFO, for the level of New and
+	// SetLevel and the other level options. They come on top of the aliases
+	// accepted by ParseLevel.
+	LevelAliases map[string]string
+
+	// StackTraceLevel adds the stack trace of the log call, as formatted by
+	// runtime.Stack, to every message at that level or above, e.g. ERROR.
+	// Empty by default, which disables stack traces.
+	StackTraceLevel string
+
+	// UnwrapErrors records the chain of errors logged with LogErr, ErrorErr
+	// and FatalError under "error_chain": the message and type of every layer
+	// returned by errors.Unwrap, with the result of its Fields method when it
+	// has one (Fields() map[string]interface{}).
+	UnwrapErrors bool
+
+	// TraceIDFromContext extracts the trace and span IDs of the context bound
+	// to a message (InfoContext, WithContext, slog records), e.g. from the
+	// active OpenTelemetry span, to add them as trace_id and span_id. Empty
+	// IDs are omitted.
+	TraceIDFromContext func(ctx context.Context) (traceID, spanID string)
+
+	// LevelLabels overrides the string written in the level field, keyed by the
+	// level constants, e.g. {INFO: "informational"}. Filtering still uses the
+	// level constants.
+	LevelLabels map[string]string
+
+	// LevelFormatter computes the string written in the level field from the
+	// level constant for the levels without a LevelLabels entry, e.g.
+	// strings.ToLower for "info" on the wire. Filtering still uses the level
+	// constants.
+	LevelFormatter func(level string) string
+
+	// FailureHandler is called with every message that could not be delivered
+	// to Vector and the delivery error. Returning nil marks the message as
+	// handled (e.g. written to a dead-letter queue); a non-nil error is
+	// reported as a dropped message. It is not called again for the
+	// messages it logs itself, see OnError.
+	FailureHandler func(msg *Message, err error) error
+
+	// Async hands messages to a background worker through a queue of
+	// BufferSize messages (1000 by default) instead of delivering them in the
+	// caller's goroutine. When the queue is full, messages are dropped unless
+	// BlockOnFull is set, in which case the caller waits for room.
+	Async       bool
+	BufferSize  int
+	BlockOnFull bool
+
+	// Protocol is the network used to reach Vector: "tcp" (default), "udp"
+	// or "http". UDP is connectionless, so a missing or unreachable Vector
+	// rarely surfaces as a write error and messages can be lost silently.
+	Protocol string
+
+	// HTTPEndpoint is the URL of the Vector http source messages are posted
+	// to with Protocol "http", http://VectorHost:VectorPort/ by default.
+	// Every message is posted on its own, or every batch as a JSON array, with
+	// HTTPHeaders added to the request. HTTPClient replaces the default client,
+	// which gives up after 10s. Responses other than 2xx are delivery errors.
+	HTTPEndpoint string
+	HTTPHeaders  http.Header
+	HTTPClient   *http.Client
+
+	// Compression compresses network payloads: with CompressionGzip, a TCP
+	// connection carries one gzip stream, flushed after every message or
+	// batch, and HTTP requests are sent with Content-Encoding: gzip. It is not
+	// supported with UDP.
+	Compression Compression
+
+	// IdleCheckInterval is how often the connection manager checks whether
+	// the connection has been idle for longer than the timeout (see
+	// SetTimeoutDuration). Defaults to 10s; values below 10ms are raised to 10ms.
+	IdleCheckInterval time.Duration
+
+	// TimeoutJitter adds a random duration in [0, TimeoutJitter), drawn for
+	// every connection, to the idle timeout, so that a fleet of instances
+	// started together does not close and reopen its connections in step.
+	TimeoutJitter time.Duration
+
+	// RetryOnTimeoutSameConn resumes a write that timed out (see WriteTimeout)
+	// once on the same connection, from the first byte not yet written,
+	// instead of closing the connection and writing again on a new one.
+	// Other errors, such as a reset connection or a broken pipe, still
+	// reconnect. It does not apply with Compression.
+	RetryOnTimeoutSameConn bool
+
+	// ConnectRetries makes New dial Vector up to that many more times, waiting
+	// ConnectRetryDelay (1s by default) between attempts, before giving up
+	// with the last error, e.g. while Vector starts next to the application.
+	// It only covers the first connection; see InitialBackoff for later ones.
+	ConnectRetries    int
+	ConnectRetryDelay time.Duration
+
+	// InitialBackoff enables the reconnect backoff: after a failed connection
+	// attempt the logger waits InitialBackoff before dialing again, doubling
+	// the wait after every consecutive failure up to MaxBackoff (1m by
+	// default). After MaxReconnectAttempts consecutive failures (0 means no
+	// limit) the logger stops reconnecting. Messages sent during the wait are
+	// dropped, or kept in the offline buffer and written after reconnecting
+	// when QueueDuringBackoff is set.
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	MaxReconnectAttempts int
+	QueueDuringBackoff   bool
+
+	// OfflineBufferSize keeps up to that many messages in memory while Vector
+	// is unreachable and writes them in order once the connection is back.
+	// When the buffer is full the oldest messages are dropped. With
+	// FlushOfflineOnClose, Close makes one last attempt to deliver them.
+	OfflineBufferSize   int
+	FlushOfflineOnClose bool
+
+	// MaxMemoryBytes bounds the memory held together by the async queue, the
+	// batch, the offline buffer and the write buffer of BufferedWrites (no
+	// limit by default). Past it, buffered messages are dropped, counted in
+	// Stats.Dropped, from the least severe and the oldest, down to the level
+	// of the message being buffered; a message that still does not fit is
+	// dropped itself, unless it is FATAL. Messages already in the async queue
+	// are never dropped, and the write buffer is skipped when it does not fit.
+	MaxMemoryBytes int
+
+	// DropPolicy decides what happens to messages logged while Vector is
+	// unreachable: DropOldest (default) and DropNewest pick which message the
+	// offline buffer gives up when full, Block waits up to BlockTimeout (5s by
+	// default) for the connection to come back, holding back every other
+	// message meanwhile. In async mode the wait happens in the worker: callers
+	// keep going until the queue is full, then BlockOnFull applies.
+	DropPolicy   DropPolicy
+	BlockTimeout time.Duration
+
+	// Fallback receives the messages that cannot be delivered because Vector
+	// is unreachable, e.g. a logger for a secondary cluster. Messages then go
+	// to the fallback for FailbackInterval (5s by default) before the next
+	// one tries Vector again, failing back once it is reachable. Messages are
+	// forwarded encoded for this logger. The fallback must not have this
+	// logger as its own fallback.
+	Fallback         *VectorLogger
+	FailbackInterval time.Duration
+
+	// Every connection dials VectorHost by name, so a changed DNS record is
+	// picked up on the next reconnect. ResolveEveryReconnect resolves the host
+	// explicitly instead and rotates through its addresses, e.g. the pods
+	// behind a headless Kubernetes service: each connection starts with the
+	// address after the one used last and skips those refusing it. Resolver
+	// replaces net.DefaultResolver.LookupHost.
+	ResolveEveryReconnect bool
+	Resolver              func(host string) ([]string, error)
+
+	// TimestampFormat is the Go time layout of the timestamp field, in UTC.
+	// It defaults to "2006-01-02T15:04:05.00Z". EpochTimestamps emits Unix
+	// milliseconds as a JSON number instead. Clock replaces time.Now, e.g. for
+	// deterministic timestamps in tests.
+	TimestampFormat string
+	EpochTimestamps bool
+	Clock           func() time.Time
+
+	// WriteTimeout bounds every network write so a Vector that stopped
+	// reading cannot block logging forever. A timed out write counts as a
+	// failed send and triggers a reconnect. Zero means no timeout.
+	WriteTimeout time.Duration
+
+	// ExtraWriters receive every message as JSON in addition to the main
+	// destination, e.g. a local file for debugging next to the Vector
+	// connection.
+	ExtraWriters []io.Writer
+
+	// BatchSize and BatchInterval coalesce messages sent over the network
+	// into a single write of up to BatchSize newline-delimited messages
+	// (100 by default), written at least every BatchInterval (1s by
+	// default). Batching is enabled when either is set. Fatal messages,
+	// Flush and Close write the pending batch immediately.
+	BatchSize     int
+	BatchInterval time.Duration
+
+	// BufferedWrites puts a 4 KiB buffer in front of the TCP connection, so
+	// that messages reach Vector in fewer writes: the buffer is written out
+	// when full, every FlushInterval (1s by default), on Flush and Close,
+	// and right after a fatal message. Messages still buffered when the
+	// connection fails are dropped. It is not supported with UDP or HTTP.
+	BufferedWrites bool
+	FlushInterval  time.Duration
+
+	// NoPersistentConnection dials Vector for every write and closes the
+	// connection right after it, instead of keeping one connection open.
+	// Nothing stays connected between messages, which suits strict firewalls,
+	// but every message pays for a full connection setup.
+	NoPersistentConnection bool
+
+	// Format selects the serialization of messages sent to Vector or to
+	// Writer: FormatJSON (default), FormatLogfmt, FormatRaw or FormatSyslog.
+	// SyslogFacility sets the facility (0-23) of FormatSyslog; it defaults
+	// to 1, user-level messages.
+	Format         Format
+	SyslogFacility *int
+
+	// KeepNewlines disables the escaping of newlines and other control
+	// characters in the message body for FormatRaw and FormatSyslog, where
+	// an embedded newline would split one message into several frames.
+	// FormatJSON and FormatLogfmt always escape them.
+	KeepNewlines bool
+
+	// MaxMessageBytes caps the size of an encoded message: the body of a
+	// larger message is cut and ends with "…[truncated]", so that Vector does
+	// not reject it. FATAL messages are kept whole unless TruncateFatal is
+	// set. Only the body is truncated, and only in what is sent to Vector.
+	MaxMessageBytes int
+	TruncateFatal   bool
+
+	// FieldNames renames the timestamp, application, level and message keys
+	// of FormatJSON and FormatLogfmt, e.g. {Timestamp: "@timestamp"}. Structured
+	// fields stay under "fields" in JSON and "fields.<key>" in logfmt, so they
+	// never clash with these keys.
+	FieldNames FieldNames
+
+	// OnError receives every internal error (failed sends, dropped messages,
+	// connection problems) instead of stderr. It is never called while the
+	// logger holds one of its locks, so it may log through the same logger,
+	// and may call Flush, Close or Fatal, even from the async worker;
+	// errors raised while OnError runs, and the failures of the messages it
+	// logs, even when delivered later by the async worker, are printed on
+	// InternalErrorWriter rather than passed to OnError or FailureHandler
+	// again. Without OnError, errors are printed on InternalErrorWriter,
+	// os.Stderr by default.
+	OnError             func(err error)
+	InternalErrorWriter io.Writer
+
+	// Debug prints diagnostics about the connection (connects, idle closes,
+	// lost connections, failover) on InternalErrorWriter. The logger is
+	// silent otherwise.
+	Debug bool
+
+	// DialTimeout bounds every connection attempt to Vector (no limit by
+	// default) and KeepAlive sets the TCP keep-alive period (15s by default,
+	// negative to disable, see net.Dialer). Dialer replaces both with a fully
+	// configured dialer.
+	DialTimeout time.Duration
+	KeepAlive   time.Duration
+	Dialer      *net.Dialer
+
+	// Heartbeat checks the TCP connection at that interval, without writing
+	// to it, and reconnects right away when the peer has closed it or TCP
+	// keep-alive has reset it, instead of losing the next message to the
+	// failed write. Disabled by default.
+	Heartbeat time.Duration
+
+	// LazyConnect makes New skip the initial connection, so it succeeds even
+	// when Vector is not up yet; the first message connects instead, with the
+	// usual backoff and offline buffering.
+	LazyConnect bool
+
+	// Conn is an established connection to use instead of dialing
+	// VectorHost, e.g. one end of a net.Pipe in tests. The logger never
+	// closes it for being idle. Once it fails, the logger calls Reconnect
+	// for a replacement, or stops sending to Vector if Reconnect is nil.
+	Conn      net.Conn
+	Reconnect func() (net.Conn, error)
+
+	// RateLimit caps the messages sent per second (token bucket, bursts of up
+	// to one second worth) and SampleRate keeps only that fraction of the
+	// messages, between 0 and 1. Rejected messages count as dropped; fatal
+	// messages are never rejected. Zero disables either.
+	RateLimit  int
+	SampleRate float64
+
+	// DedupWindow collapses identical consecutive messages (same level and
+	// text): the first one is sent right away and the repeats seen within
+	// the window are summarized in a single "<message> (repeated N times)"
+	// message carrying the count in the "repeated" field. The summary is sent
+	// when the window ends, when a different message arrives, or on Close.
+	DedupWindow time.Duration
+
+	// IncludeHostname and IncludePID add the hostname and process ID, resolved
+	// once by New, to every message.
+	IncludeHostname bool
+	IncludePID      bool
+
+	// SequenceNumbers numbers the messages under "seq", from 1, with one
+	// counter shared by the loggers derived from this one, so that consumers
+	// can spot lost or duplicated messages. Messages of a goroutine are
+	// written in order, but concurrent ones may reach Vector in a different
+	// order than their numbers. A write retried after a failure, or kept in
+	// the offline buffer, is resent as is: Vector may then receive the same
+	// number twice, never two messages with the same number.
+	SequenceNumbers bool
+
+	// DefaultFields are attached to every message, e.g. service_version, env
+	// or region. Fields passed per call or through With take precedence. The
+	// map is copied by New, so later changes to it have no effect.
+	DefaultFields map[string]interface{}
+
+	// FlattenFields replaces nested maps (map[string]interface{}) of the
+	// fields by their entries under dotted keys, recursively, e.g.
+	// {"http": {"status": 200}} becomes {"http.status": 200}, for schemas
+	// expecting flat keys. Slices are kept as arrays unless FlattenSlices is
+	// set, which flattens them under their indexes ("tags.0"). When a dotted
+	// key is given directly as well, the value nested the least wins.
+	FlattenFields bool
+	FlattenSlices bool
+
+	// RedactKeys replaces the value of every field, or tag, whose key is one
+	// of them, case-insensitively, by "[REDACTED]", e.g. password or token.
+	// RedactKeyPattern does the same for the keys it matches. Fields nested
+	// in maps are redacted too, whether or not FlattenFields is set; the
+	// content of structs is not inspected. RedactPatterns replace the
+	// substrings of the message they match by "[REDACTED]".
+	RedactKeys       []string
+	RedactKeyPattern *regexp.Regexp
+	RedactPatterns   []*regexp.Regexp
+
+	// Disabled turns logging off: messages are discarded and the logger
+	// never connects to Vector nor starts background goroutines, while its
+	// methods keep working, e.g. SetLevel or Close. See NewNop.
+	Disabled bool
+
+	// NoExit keeps Fatal, Fatalf and FatalError from exiting the process
+	// once the message is flushed, e.g. in tests.
+	NoExit bool
 }
 
 // VectorLogger represents a logger instance.
+//
+// The zero value is a valid logger without any destination: it never dials,
+// starts no goroutines and discards every message. Use New to configure one.
 type VectorLogger struct {
 	Application string // Application name.
-	Level       string // Log level.
+	Level       string // Initial log level; use GetLevel and SetLevel once the logger is in use.
 	VectorHost  string // Vector host.
 	VectorPort  int64  // Vector port.
 	Options     Options
+
+	fields map[string]interface{} // Fields attached to every message; never mutated after creation.
+	tags   map[string]string      // Tags attached to every message; never mutated after creation.
+	ctx    context.Context        // Context bound by WithContext, if any.
+
+	*core        // Connection state, shared with the loggers derived from this one.
+	derived bool // Set on loggers returned by With and friends; Close is a no-op for them.
 }
 
 func New(application string, level string, vectorHost string, vectorPort int64, options ...Options) (*VectorLogger, error) {
@@ -45,13 +507,119 @@ func New(application string, level string, vectorHost string, vectorPort int64,
 		return nil, fmt.Errorf("Can only pass in one Options struct")
 	}
 
-	return &VectorLogger{
+	switch opts.Protocol {
+	case "", "tcp", "udp":
+	case "http":
+		if opts.StreamHeader {
+			return nil, fmt.Errorf("StreamHeader is not supported with the http protocol")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q, expected tcp, udp or http", opts.Protocol)
+	}
+	if _, err := encoderFor(&opts); err != nil {
+		return nil, err
+	}
+	if err := validateCompression(&opts); err != nil {
+		return nil, err
+	}
+	if err := validateFraming(&opts); err != nil {
+		return nil, err
+	}
+	if err := validateBufferedWrites(&opts); err != nil {
+		return nil, err
+	}
+	if err := opts.FieldNames.validate(); err != nil {
+		return nil, err
+	}
+	aliases, err := normalizeLevelAliases(opts.LevelAliases)
+	if err != nil {
+		return nil, err
+	}
+	opts.LevelAliases = aliases
+	parsedLevel, err := parseLevel(level, opts.LevelAliases)
+	if err != nil {
+		return nil, err
+	}
+	if vectorHost, err = normalizeHost(vectorHost); err != nil {
+		return nil, err
+	}
+	if vectorHost != "" && opts.HTTPEndpoint == "" && (vectorPort < 1 || vectorPort > 65535) {
+		return nil, fmt.Errorf("invalid vector port %d for host %q, expected 1-65535", vectorPort, vectorHost)
+	}
+	if opts.StackTraceLevel != "" {
+		if opts.StackTraceLevel, err = parseLevel(opts.StackTraceLevel, opts.LevelAliases); err != nil {
+			return nil, fmt.Errorf("invalid stack trace level: %w", err)
+		}
+	}
+	if opts.MirrorToStderrLevel != "" {
+		if opts.MirrorToStderrLevel, err = parseLevel(opts.MirrorToStderrLevel, opts.LevelAliases); err != nil {
+			return nil, fmt.Errorf("invalid stderr mirror level: %w", err)
+		}
+	}
+
+	logger := &VectorLogger{
 		Application: application,
-		Level:       strings.ToUpper(level),
+		Level:       parsedLevel,
 		VectorHost:  vectorHost,
 		VectorPort:  vectorPort,
 		Options:     opts,
-	}, nil
+		core:        newCore(parsedLevel),
+	}
+	if opts.IncludeHostname {
+		hostname, errHostname := os.Hostname()
+		if errHostname != nil {
+			return nil, fmt.Errorf("cannot resolve the hostname: %w", errHostname)
+		}
+		logger.hostname = hostname
+	}
+	if opts.IncludePID {
+		logger.pid = os.Getpid()
+	}
+	logger.color = useColor(&opts)
+	logger.redactKeys = redactKeySet(opts.RedactKeys)
+	if len(opts.DefaultFields) > 0 {
+		logger.fields = make(map[string]interface{}, len(opts.DefaultFields))
+		for k, v := range opts.DefaultFields {
+			logger.fields[k] = v
+		}
+	}
+
+	if opts.Disabled {
+		return logger, nil
+	}
+
+	// Connect eagerly so a misconfigured endpoint is reported right away.
+	// Every validation must stay above: once connected, New must not fail,
+	// or the connection and the goroutines started below would leak.
+	if opts.Conn != nil {
+		logger.setConn(opts.Conn)
+	} else if logger.usesNetwork() && !logger.usesHTTP() && !opts.NoPersistentConnection {
+		if !opts.LazyConnect {
+			if err := logger.connectAtStartup(); err != nil {
+				return nil, err
+			}
+		}
+		logger.startConnectionManager()
+	}
+	if opts.Async {
+		logger.startAsyncWorker()
+	}
+	if logger.batching() {
+		logger.startBatcher()
+	}
+	if opts.BufferedWrites && logger.usesNetwork() {
+		logger.startFlusher()
+	}
+
+	return logger, nil
+}
+
+// NewNop returns a logger discarding every message, for tests or when
+// logging is off: it has Options.Disabled and Options.NoExit set, so that it
+// never touches the network and its Fatal methods return.
+func NewNop() *VectorLogger {
+	logger, _ := New("", INFO, "", 0, Options{Disabled: true, NoExit: true})
+	return logger
 }
 
 // Message represents a log message.
@@ -60,10 +628,138 @@ type Message struct {
 	Application string `json:"application"` // Application name.
 	Level       string `json:"level"`       // Log level.
 	Message     string `json:"message"`     // Log message.
+
+	Event string `json:"event,omitempty"` // Name of the event logged by Event.
+
+	Hostname string `json:"hostname,omitempty"` // Host the message comes from, see Options.IncludeHostname.
+	PID      int    `json:"pid,omitempty"`      // Process ID, see Options.IncludePID.
+	Seq      uint64 `json:"seq,omitempty"`      // Sequence number, see Options.SequenceNumbers.
+
+	File     string `json:"file,omitempty"`     // Source file of the log call, see Options.IncludeCaller.
+	Line     int    `json:"line,omitempty"`     // Source line of the log call.
+	Function string `json:"function,omitempty"` // Fully qualified function of the log call.
+
+	Stacktrace string `json:"stacktrace,omitempty"` // Stack of the log call, see Options.StackTraceLevel.
+
+	TraceID string `json:"trace_id,omitempty"` // Trace of the context of the log call, see Options.TraceIDFromContext.
+	SpanID  string `json:"span_id,omitempty"`  // Span of the context of the log call.
+
+	Fields map[string]interface{} `json:"fields,omitempty"` // Structured fields attached to the message.
+	Tags   map[string]string      `json:"tags,omitempty"`   // Low-cardinality, indexed tags attached to the message.
+
+	level   string          // Internal level constant; Level may carry a custom label.
+	epoch   bool            // Timestamp holds Unix milliseconds and is emitted as a JSON number.
+	time    time.Time       // Time of the log call, Timestamp before formatting.
+	size    int             // Memory accounted for the message while in the async queue.
+	ctx     context.Context // Context of the log call, if any; bounds the network write.
+	flushed chan struct{}   // Set on the marker queued by Flush; closed once reached.
+
+	// inCallback is set on messages logged while OnError or FailureHandler
+	// runs, whose failures are printed instead, see handleFailure.
+	inCallback bool
+}
+
+// Measurement is a numeric field value carrying its unit, serialized as
+// {"value": 123, "unit": "ms"}.
+type Measurement struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// String renders the measurement as "<value> <unit>".
+func (m Measurement) String() string {
+	if m.Unit == "" {
+		return strconv.FormatFloat(m.Value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(m.Value, 'f', -1, 64) + " " + m.Unit
+}
+
+// With returns a child logger that attaches fields to every message. The child
+// shares the connection and background goroutines of its parent; nested With
+// calls accumulate fields. Closing a child does nothing, only closing the root
+// logger closes the connection.
+func (l *VectorLogger) With(fields map[string]interface{}) *VectorLogger {
+	return l.withFields(fields)
+}
+
+// WithMeasurement returns a child logger that attaches the numeric value with
+// its unit under key to every message, e.g. WithMeasurement("latency", 123, "ms").
+// Measurements accumulate across calls.
+func (l *VectorLogger) WithMeasurement(key string, value float64, unit string) *VectorLogger {
+	return l.withFields(map[string]interface{}{key: Measurement{Value: value, Unit: unit}})
+}
+
+// WithTag returns a child logger that attaches the tag key=value to every
+// message. Tags are meant for low-cardinality values that the backend should
+// index and are emitted under "tags", apart from the regular "fields".
+func (l *VectorLogger) WithTag(key string, value string) *VectorLogger {
+	child := l.derive()
+	child.tags = make(map[string]string, len(l.tags)+1)
+	for k, v := range l.tags {
+		child.tags[k] = v
+	}
+	child.tags[key] = value
+	return child
+}
+
+// withFields returns a copy of the logger whose fields are the logger's own
+// fields merged with extra. Keys in extra win.
+func (l *VectorLogger) withFields(extra map[string]interface{}) *VectorLogger {
+	child := l.derive()
+	child.fields = make(map[string]interface{}, len(l.fields)+len(extra))
+	for k, v := range l.fields {
+		child.fields[k] = v
+	}
+	for k, v := range extra {
+		child.fields[k] = v
+	}
+	return child
+}
+
+// withApplication returns a copy of the logger sending its messages under
+// application.
+func (l *VectorLogger) withApplication(application string) *VectorLogger {
+	child := l.derive()
+	child.Application = application
+	return child
+}
+
+// derive returns a child logger sharing the connection state of l.
+func (l *VectorLogger) derive() *VectorLogger {
+	child := *l
+	child.derived = true
+	return &child
+}
+
+// Clone returns an independent logger with the configuration of l: its
+// application, current level, endpoint, options, fields and tags. Unlike
+// With, the clone opens its own connection and runs its own background
+// goroutines, and closing it leaves l untouched. A logger given Options.Conn
+// can only be cloned with Options.Reconnect, which provides the connection
+// of the clone.
+func (l *VectorLogger) Clone() (*VectorLogger, error) {
+	if l.core == nil {
+		return nil, fmt.Errorf("cannot clone a logger not created by New")
+	}
+	opts := l.Options
+	if opts.Conn != nil {
+		if opts.Reconnect == nil {
+			return nil, fmt.Errorf("cannot clone a logger given Options.Conn without Options.Reconnect")
+		}
+		opts.Conn = nil
+	}
+
+	clone, err := New(l.Application, l.GetLevel(), l.VectorHost, l.VectorPort, opts)
+	if err != nil {
+		return nil, err
+	}
+	clone.fields, clone.tags, clone.ctx = l.fields, l.tags, l.ctx
+	return clone, nil
 }
 
 // Init initializes the logger instance. This method is deprecated; use
-// New() with a Options struct for more flexibility.
+// New() with a Options struct for more flexibility. Like a logger built as
+// a struct literal, it dials one connection per message and needs no Close.
 func (l *VectorLogger) Init(application string, level string, vectorHost string, vectorPort int64) {
 	l.Application = application
 	l.Level = strings.ToUpper(level)
@@ -72,9 +768,26 @@ func (l *VectorLogger) Init(application string, level string, vectorHost string,
 	l.Options.AlsoPrintMessages = true
 }
 
+// Tracef logs a trace message, finer-grained than debug, with a formatted
+// string.
+func (l *VectorLogger) Tracef(format string, v ...interface{}) {
+	if !l.shouldLog(TRACE) {
+		return
+	}
+	l.sendMessage(fmt.Sprintf(format, v...), TRACE)
+}
+
+// Trace logs a trace message, finer-grained than debug.
+func (l *VectorLogger) Trace(message string) {
+	if !l.shouldLog(TRACE) {
+		return
+	}
+	l.sendMessage(message, TRACE)
+}
+
 // Debugf logs a debug message with a formatted string.
 func (l *VectorLogger) Debugf(format string, v ...interface{}) {
-	if l.Level != DEBUG {
+	if !l.shouldLog(DEBUG) {
 		return
 	}
 	l.sendMessage(fmt.Sprintf(format, v...), DEBUG)
@@ -82,7 +795,7 @@ func (l *VectorLogger) Debugf(format string, v ...interface{}) {
 
 // Debug logs a debug message.
 func (l *VectorLogger) Debug(message string) {
-	if l.Level != DEBUG {
+	if !l.shouldLog(DEBUG) {
 		return
 	}
 	l.sendMessage(message, DEBUG)
@@ -90,23 +803,23 @@ func (l *VectorLogger) Debug(message string) {
 
 // Infof logs an info message with a formatted string.
 func (l *VectorLogger) Infof(format string, v ...interface{}) {
-	if (l.Level == ERROR) || (l.Level == WARN) {
+	if !l.shouldLog(INFO) {
 		return
 	}
-	l.sendMessage(fmt.Sprintf(format, v...), "INFO")
+	l.sendMessage(fmt.Sprintf(format, v...), INFO)
 }
 
 // Info logs an info message.
 func (l *VectorLogger) Info(message string) {
-	if (l.Level == ERROR) || (l.Level == WARN) {
+	if !l.shouldLog(INFO) {
 		return
 	}
-	l.sendMessage(message, "INFO")
+	l.sendMessage(message, INFO)
 }
 
 // Warnf logs an warning message with a formatted string.
 func (l *VectorLogger) Warnf(format string, v ...interface{}) {
-	if l.Level == ERROR {
+	if !l.shouldLog(WARN) {
 		return
 	}
 	l.sendMessage(fmt.Sprintf(format, v...), WARN)
@@ -114,7 +827,7 @@ func (l *VectorLogger) Warnf(format string, v ...interface{}) {
 
 // Warn logs an warning message.
 func (l *VectorLogger) Warn(message string) {
-	if l.Level == ERROR {
+	if !l.shouldLog(WARN) {
 		return
 	}
 	l.sendMessage(message, WARN)
@@ -122,81 +835,735 @@ func (l *VectorLogger) Warn(message string) {
 
 // Errorf logs an error message with a formatted string.
 func (l *VectorLogger) Errorf(format string, v ...interface{}) {
+	if !l.shouldLog(ERROR) {
+		return
+	}
 	l.sendMessage(fmt.Sprintf(format, v...), ERROR)
 }
 
 // Error logs an error message.
 func (l *VectorLogger) Error(message string) {
+	if !l.shouldLog(ERROR) {
+		return
+	}
 	l.sendMessage(message, ERROR)
 }
 
-// Errorf logs an error message with a formatted string.
+// DebugFields logs a debug message with structured fields.
+func (l *VectorLogger) DebugFields(message string, fields map[string]interface{}) {
+	if !l.shouldLog(DEBUG) {
+		return
+	}
+	l.withFields(fields).sendMessage(message, DEBUG)
+}
+
+// InfoFields logs an info message with structured fields. The fields are
+// emitted under "fields", merged with the logger's own, so they can never
+// overwrite the timestamp, application, level or message keys.
+func (l *VectorLogger) InfoFields(message string, fields map[string]interface{}) {
+	if !l.shouldLog(INFO) {
+		return
+	}
+	l.withFields(fields).sendMessage(message, INFO)
+}
+
+// WarnFields logs a warning message with structured fields.
+func (l *VectorLogger) WarnFields(message string, fields map[string]interface{}) {
+	if !l.shouldLog(WARN) {
+		return
+	}
+	l.withFields(fields).sendMessage(message, WARN)
+}
+
+// ErrorFields logs an error message with structured fields.
+func (l *VectorLogger) ErrorFields(message string, fields map[string]interface{}) {
+	if !l.shouldLog(ERROR) {
+		return
+	}
+	l.withFields(fields).sendMessage(message, ERROR)
+}
+
+// TraceAs logs a trace message under application instead of the
+// application of the logger.
+func (l *VectorLogger) TraceAs(application string, message string) {
+	if !l.shouldLog(TRACE) {
+		return
+	}
+	l.withApplication(application).sendMessage(message, TRACE)
+}
+
+// DebugAs logs a debug message under application instead of the
+// application of the logger.
+func (l *VectorLogger) DebugAs(application string, message string) {
+	if !l.shouldLog(DEBUG) {
+		return
+	}
+	l.withApplication(application).sendMessage(message, DEBUG)
+}
+
+// InfoAs logs an info message under application instead of the application
+// of the logger, e.g. for one of several services hosted by the process.
+// Only that message is affected.
+func (l *VectorLogger) InfoAs(application string, message string) {
+	if !l.shouldLog(INFO) {
+		return
+	}
+	l.withApplication(application).sendMessage(message, INFO)
+}
+
+// WarnAs logs a warning message under application instead of the
+// application of the logger.
+func (l *VectorLogger) WarnAs(application string, message string) {
+	if !l.shouldLog(WARN) {
+		return
+	}
+	l.withApplication(application).sendMessage(message, WARN)
+}
+
+// ErrorAs logs an error message under application instead of the
+// application of the logger.
+func (l *VectorLogger) ErrorAs(application string, message string) {
+	if !l.shouldLog(ERROR) {
+		return
+	}
+	l.withApplication(application).sendMessage(message, ERROR)
+}
+
+// TryDebug logs a debug message like Debug, but delivers it synchronously
+// and returns the delivery error, if any.
+func (l *VectorLogger) TryDebug(message string) error {
+	if !l.shouldLog(DEBUG) {
+		return nil
+	}
+	return l.trySendMessage(message, DEBUG)
+}
+
+// TryInfo logs an info message like Info, but delivers it synchronously and
+// returns the delivery error, e.g. a refused connection or a write timeout.
+func (l *VectorLogger) TryInfo(message string) error {
+	if !l.shouldLog(INFO) {
+		return nil
+	}
+	return l.trySendMessage(message, INFO)
+}
+
+// TryWarn logs a warning message like Warn, but delivers it synchronously
+// and returns the delivery error, if any.
+func (l *VectorLogger) TryWarn(message string) error {
+	if !l.shouldLog(WARN) {
+		return nil
+	}
+	return l.trySendMessage(message, WARN)
+}
+
+// TryError logs an error message like Error, but delivers it synchronously
+// and returns the delivery error, if any.
+func (l *VectorLogger) TryError(message string) error {
+	if !l.shouldLog(ERROR) {
+		return nil
+	}
+	return l.trySendMessage(message, ERROR)
+}
+
+// LogErr logs err at the error level, with the error text in the "error"
+// field, and returns it unchanged: return l.LogErr(fmt.Errorf(...)).
+// A nil error is returned without logging anything.
+func (l *VectorLogger) LogErr(err error) error {
+	if err == nil || !l.shouldLog(ERROR) {
+		return err
+	}
+	l.withFields(l.errorFields(err)).sendMessage(err.Error(), ERROR)
+	return err
+}
+
+// ErrorErr logs err at the error level like LogErr, with the wrapped errors
+// of its chain when Options.UnwrapErrors is set. A nil error is ignored.
+func (l *VectorLogger) ErrorErr(err error) {
+	if err == nil || !l.shouldLog(ERROR) {
+		return
+	}
+	l.withFields(l.errorFields(err)).sendMessage(err.Error(), ERROR)
+}
+
+// Fatalf logs a fatal message with a formatted string, flushes the logger
+// and exits, unless Options.NoExit is set.
 func (l *VectorLogger) Fatalf(format string, v ...interface{}) {
 	l.sendMessage(fmt.Sprintf(format, v...), FATAL)
-	os.Exit(1)
+	_ = l.Flush()
+	l.exit()
 }
 
-// Fatal logs an error message.
+// Fatal logs a fatal message, flushes the logger and exits, unless
+// Options.NoExit is set.
 func (l *VectorLogger) Fatal(message string) {
 	l.sendMessage(message, FATAL)
-	os.Exit(1)
+	_ = l.Flush()
+	l.exit()
 }
 
-// Fatal logs an error message.
+// FatalError logs an error at the fatal level, with the same fields as
+// ErrorErr, and exits, unless Options.NoExit is set. A nil error is ignored
+// and does not exit.
 func (l *VectorLogger) FatalError(message error) {
-	l.sendMessage(message.Error(), FATAL)
-	os.Exit(1)
+	if message == nil {
+		return
+	}
+	l.withFields(l.errorFields(message)).sendMessage(message.Error(), FATAL)
+	_ = l.Flush()
+	l.exit()
+}
+
+// exit ends the process after a fatal message, unless Options.NoExit is set.
+func (l *VectorLogger) exit() {
+	if !l.Options.NoExit {
+		os.Exit(1)
+	}
+}
+
+// VerifyConnectivity checks that the Vector endpoint accepts connections by
+// dialing it (and closing the connection again) up to attempts times, sleeping
+// delay between attempts. It is meant for deployment smoke tests; the returned
+// error lists the endpoint and the outcome of every failed attempt, and
+// Stats.VerifyAttempts tells how many attempts the call made.
+func (l *VectorLogger) VerifyConnectivity(attempts int, delay time.Duration) error {
+	if l.Options.Disabled {
+		return fmt.Errorf("cannot verify connectivity: logging is disabled")
+	}
+	if l.VectorHost == "" {
+		return fmt.Errorf("cannot verify connectivity: vector host is not set")
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	addr := l.address()
+	failures := make([]string, 0, attempts)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if l.core != nil {
+			l.stats.verifyAttempts.Store(uint64(attempt))
+		}
+		conn, err := l.dialer().Dial(l.network(), addr)
+		if err == nil {
+			if errClose := conn.Close(); errClose != nil {
+				return fmt.Errorf("connected to vector on %s at attempt %d/%d but cannot close the connection: %w", addr, attempt, attempts, errClose)
+			}
+			return nil
+		}
+		failures = append(failures, fmt.Sprintf("attempt %d/%d: %v", attempt, attempts, err))
+		if attempt < attempts {
+			time.Sleep(delay)
+		}
+	}
+
+	return fmt.Errorf("cannot connect to vector on %s after %d attempts (%s)", addr, attempts, strings.Join(failures, "; "))
+}
+
+// normalizeHost strips the brackets of an IPv6 literal such as "[::1]", which
+// address adds back, and rejects malformed hosts.
+func normalizeHost(host string) (string, error) {
+	if !strings.HasPrefix(host, "[") && !strings.HasSuffix(host, "]") {
+		return host, nil
+	}
+	inner := strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if len(inner) != len(host)-2 || !strings.Contains(inner, ":") {
+		return "", fmt.Errorf("invalid vector host %q, brackets are only allowed around an IPv6 address", host)
+	}
+	return inner, nil
 }
 
-// send sends the log message to stdout and to a remote Vector instance.
+// network returns the network used to reach Vector.
+func (l *VectorLogger) network() string {
+	if l.Options.Protocol == "" || l.usesHTTP() {
+		return "tcp"
+	}
+	return l.Options.Protocol
+}
+
+// address returns the host:port of the Vector instance.
+func (l *VectorLogger) address() string {
+	if l.VectorHost == "" && l.Options.Conn != nil {
+		return l.Options.Conn.RemoteAddr().String()
+	}
+	return net.JoinHostPort(l.VectorHost, strconv.FormatInt(l.VectorPort, 10))
+}
+
+// send sends the log message to stdout and then delivers it, either directly
+// or through the async queue.
 func (l *VectorLogger) send(msg *Message) {
-	// Write logs to the stdout with different (human-readable) format
-	if l.Options.AlsoPrintMessages {
-		_, _ = fmt.Fprintf(os.Stdout, "%23s | %5s | %s\n", msg.Timestamp, msg.Level, msg.Message)
+	l.stamp(msg)
+	l.print(msg)
+	if l.enqueue(msg) {
+		return
+	}
+	l.deliver(msg)
+}
+
+// print writes the log message to stdout, or to Options.ConsoleWriter, in a
+// human-readable format, see Options.ConsoleFormat. Messages at
+// Options.MirrorToStderrLevel or above go to stderr instead when they are
+// not printed otherwise. A failed print is reported once, until printing
+// works again.
+func (l *VectorLogger) print(msg *Message) {
+	prints := l.prints()
+	mirrors := l.mirrors(msg.level) && !prints
+	if !prints && !mirrors {
+		return
+	}
+	format := l.Options.ConsoleFormat
+	if format == nil {
+		format = defaultConsoleFormat
 	}
+	line := format(msg) + "\n"
 
-	dest := l.Options.Writer
-	if dest == nil {
-		// Setup network connection if the host is set
-		if l.VectorHost == "" {
-			return
+	var err error
+	if prints {
+		printed := line
+		if l.core != nil && l.color {
+			printed = format(colorize(msg)) + "\n"
 		}
+		switch {
+		case l.Options.ConsoleWriter == nil:
+			_, err = io.WriteString(os.Stdout, printed)
+		case l.core == nil:
+			_, err = io.WriteString(l.Options.ConsoleWriter, printed)
+		default:
+			l.consoleMu.Lock()
+			_, err = io.WriteString(l.Options.ConsoleWriter, printed)
+			l.consoleMu.Unlock()
+		}
+	}
+	if mirrors {
+		if _, errMirror := io.WriteString(os.Stderr, line); err == nil {
+			err = errMirror
+		}
+	}
+	if l.core == nil {
+		return
+	}
+	if err == nil {
+		l.printFailing.Store(false)
+	} else if !l.printFailing.Swap(true) {
+		l.reportError(fmt.Errorf("cannot print log message: %w", err))
+	}
+}
 
-		// Send logs to the vector if the host is set
-		conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", l.VectorHost, l.VectorPort))
-		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "[ERROR] cannot send logs to vector on: %s:%d: %v\n", l.VectorHost, l.VectorPort, err)
-			return
+// prints reports whether messages are printed in a human-readable format.
+func (l *VectorLogger) prints() bool {
+	return l.Options.AlsoPrintMessages || l.Options.ConsoleWriter != nil
+}
+
+// mirrors reports whether a message at level is printed to stderr, see
+// Options.MirrorToStderrLevel.
+func (l *VectorLogger) mirrors(level string) bool {
+	threshold, ok := levelSeverity[l.Options.MirrorToStderrLevel]
+	if !ok {
+		return false
+	}
+	severity, ok := levelSeverity[level]
+	return ok && severity >= threshold
+}
+
+// defaultConsoleFormat is the layout of printed messages when
+// Options.ConsoleFormat is not set.
+func defaultConsoleFormat(msg *Message) string {
+	return fmt.Sprintf("%23s | %5s | %s", msg.Timestamp, msg.Level, msg.Message)
+}
+
+// deliver sends the log message to a remote Vector instance and to every
+// extra encoder sink.
+func (l *VectorLogger) deliver(msg *Message) {
+	if msg.ctx != nil && msg.ctx.Err() != nil {
+		l.handleFailure(msg, fmt.Errorf("cannot send logs to vector: %w", msg.ctx.Err()))
+		return
+	}
+
+	if err := l.sendToVector(msg); err != nil {
+		l.handleFailure(msg, err)
+	}
+
+	l.writeExtraSinks(msg)
+}
+
+// writeExtraSinks writes the message to every extra writer and extra encoder
+// sink. A failing sink does not prevent delivery to the others.
+func (l *VectorLogger) writeExtraSinks(msg *Message) {
+	if len(l.Options.ExtraWriters) == 0 && len(l.Options.ExtraEncoders) == 0 {
+		return
+	}
+
+	sinks := make([]EncoderSink, 0, len(l.Options.ExtraWriters)+len(l.Options.ExtraEncoders))
+	for _, w := range l.Options.ExtraWriters {
+		sinks = append(sinks, EncoderSink{Encoder: JSONEncoder{}, Writer: w})
+	}
+	sinks = append(sinks, l.Options.ExtraEncoders...)
+
+	var errs []error
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if l.core != nil {
+		l.mu.Lock()
+	}
+	for _, sink := range sinks {
+		buf.Reset()
+		if errEncode := sink.Encoder.Encode(buf, msg); errEncode != nil {
+			errs = append(errs, fmt.Errorf("cannot encode log msg for extra sink: %w", errEncode))
+			continue
 		}
-		defer func(conn net.Conn) {
-			err := conn.Close()
-			if err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "[ERROR] cannot close the connection to vector on: %s:%d: %v\n", l.VectorHost, l.VectorPort, err)
-			}
-		}(conn)
-		dest = conn
+		if _, errSend := buf.WriteTo(sink.Writer); errSend != nil {
+			errs = append(errs, fmt.Errorf("cannot write log msg to extra sink: %w", errSend))
+		}
+	}
+	if l.core != nil {
+		l.unlock()
 	}
 
-	// Convert the JSON object to bytes
-	buf := new(bytes.Buffer)
-	if errMarshal := json.NewEncoder(buf).Encode(msg); errMarshal != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "[ERROR] cannot marshal log msg: %v\n", errMarshal)
+	for _, err := range errs {
+		l.reportError(err)
+	}
+}
+
+// handleFailure passes a message that could not be delivered to the
+// FailureHandler and reports it unless the handler absorbed it. The failure
+// of a message logged by OnError or FailureHandler is printed instead.
+func (l *VectorLogger) handleFailure(msg *Message, err error) {
+	if l.core != nil {
+		l.stats.failed.Add(1)
+		l.mu.Lock()
+		l.setLastError(err)
+		l.mu.Unlock()
+	}
+	if msg.inCallback {
+		// Handing it to the callbacks that logged it could call them again
+		// without end, e.g. through the async worker.
+		l.stats.dropped.Add(1)
+		_, _ = fmt.Fprintf(l.internalErrorWriter(), "[ERROR] %v\n", err)
 		return
 	}
+	if l.Options.FailureHandler != nil {
+		l.callback(func() { err = l.Options.FailureHandler(msg, err) })
+	}
+	if err != nil {
+		if l.core != nil {
+			l.stats.dropped.Add(1)
+		}
+		l.reportError(err)
+	}
+}
+
+// sendToVector sends the encoded log message to the configured Writer or
+// to a remote Vector instance and returns the delivery error, if any.
+func (l *VectorLogger) sendToVector(msg *Message) error {
+	if l.writer() == nil && !l.usesNetwork() && !l.dialsPerMessage() {
+		return nil
+	}
+
+	// Convert the JSON object to bytes
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := l.encode(buf, msg); err != nil {
+		return err
+	}
+	if l.dialsPerMessage() {
+		return l.writeOwnConn(buf.Bytes())
+	}
+
+	if l.core != nil {
+		l.mu.Lock()
+		defer l.unlock()
+	}
+
+	if writer := l.writer(); writer != nil {
+		if _, errSend := buf.WriteTo(writer); errSend != nil {
+			return fmt.Errorf("cannot send data to vector: %w", errSend)
+		}
+		if l.core != nil {
+			l.stats.sent.Add(1)
+			l.setLastError(nil)
+		}
+		return nil
+	}
+
+	// Batch until Close stops the batcher, then write directly
+	var err error
+	if l.batching() && !l.closed.Load() {
+		l.addToBatch(buf.Bytes(), msg)
+	} else {
+		// Send the log bytes to the TCP socket
+		var deadline time.Time
+		if msg.ctx != nil {
+			deadline, _ = msg.ctx.Deadline()
+		}
+		err = l.writeToConn(buf.Bytes(), 1, msg.level, deadline)
+	}
+	if err == nil && msg.level == FATAL {
+		err = l.flushFatal()
+	}
+	return err
+}
+
+// encodeRecord appends the record of msg to buf, preceded by its stream
+// header when Options.StreamHeader is set. The body of a message exceeding
+// Options.MaxMessageBytes once encoded is truncated.
+func (l *VectorLogger) encodeRecord(buf *bytes.Buffer, msg *Message) error {
+	encoder, err := encoderFor(&l.Options)
+	if err != nil {
+		return err
+	}
+	start := buf.Len()
+	if l.Options.StreamHeader {
+		buf.WriteByte(StreamID(msg.level))
+	}
+	if errMarshal := encoder.Encode(buf, msg); errMarshal != nil {
+		return fmt.Errorf("cannot marshal log msg: %w", errMarshal)
+	}
+
+	limit := l.Options.MaxMessageBytes
+	if limit <= 0 || buf.Len()-start <= limit || (msg.level == FATAL && !l.Options.TruncateFatal) {
+		return nil
+	}
+
+	// Search the longest body prefix that fits once encoded. Encoding never
+	// shrinks the text, so the prefix is at most limit bytes long.
+	truncated := *msg
+	encodeWith := func(n int) error {
+		buf.Truncate(start)
+		if l.Options.StreamHeader {
+			buf.WriteByte(StreamID(msg.level))
+		}
+		truncated.Message = truncateText(msg.Message, n) + truncationMarker
+		if errMarshal := encoder.Encode(buf, &truncated); errMarshal != nil {
+			return fmt.Errorf("cannot marshal log msg: %w", errMarshal)
+		}
+		return nil
+	}
+	low, high := 0, limit
+	if high > len(msg.Message) {
+		high = len(msg.Message)
+	}
+	for low < high {
+		mid := (low + high + 1) / 2
+		if err := encodeWith(mid); err != nil {
+			return err
+		}
+		if buf.Len()-start <= limit {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return encodeWith(low)
+}
+
+// truncationMarker ends the body of messages truncated by Options.MaxMessageBytes.
+const truncationMarker = "…[truncated]"
 
-	// Send the log bytes to the TCP socket
-	if _, errSend := buf.WriteTo(dest); errSend != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "[ERROR] cannot send data to vector: %v\n", errSend)
+// truncateText returns the longest prefix of text of at most n bytes that
+// does not split a UTF-8 sequence.
+func truncateText(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(text) {
+		return text
 	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// usesNetwork reports whether messages go to Vector over the network rather
+// than to a writer. Only loggers created by New manage a connection;
+// the others dial one per message, see dialsPerMessage.
+func (l *VectorLogger) usesNetwork() bool {
+	return l.core != nil && !l.Options.Disabled && l.writer() == nil &&
+		(l.VectorHost != "" || l.Options.HTTPEndpoint != "" || l.Options.Conn != nil || l.Options.Reconnect != nil)
+}
+
+// dialsPerMessage reports whether messages go to Vector over a connection
+// dialed for each of them, as for a VectorLogger built as a struct literal
+// with VectorHost set, which has no managed connection.
+func (l *VectorLogger) dialsPerMessage() bool {
+	return l.core == nil && !l.Options.Disabled && l.writer() == nil && l.VectorHost != "" && !l.usesHTTP()
 }
 
 // wrapper for sending a log message
 func (l *VectorLogger) sendMessage(message string, level string) {
+	if !l.hasDestination() || !l.admit(level) {
+		return
+	}
+	msg := l.newMessage(message, level)
+	if l.dedup(msg) {
+		return
+	}
+	l.send(msg)
+}
+
+// trySendMessage delivers a log message synchronously, bypassing the async
+// queue, and returns the error of the delivery to Vector.
+func (l *VectorLogger) trySendMessage(message string, level string) error {
+	if !l.hasDestination() || !l.admit(level) {
+		return nil
+	}
+	msg := l.newMessage(message, level)
+	l.stamp(msg)
+	l.print(msg)
+
+	err := l.sendToVector(msg)
+	if err != nil {
+		l.handleFailure(msg, err)
+	}
+	l.writeExtraSinks(msg)
+	return err
+}
+
+// newMessage builds the message for a log call at level.
+func (l *VectorLogger) newMessage(message string, level string) *Message {
+	msg := l.buildMessage(message, level)
+	if l.Options.IncludeCaller {
+		if c, ok := captureCaller(callerSkip); ok {
+			msg.File, msg.Line, msg.Function = c.File, c.Line, c.Function
+		}
+	}
+	return msg
+}
+
+// buildMessage is newMessage without the caller, for callers capturing it
+// once for several messages, see WriteRaw.
+func (l *VectorLogger) buildMessage(message string, level string) *Message {
+	now := l.now()
 	newMessage := Message{
-		Timestamp:   time.Now().UTC().Format("2006-01-02T15:04:05.00Z"),
+		Timestamp:   l.timestamp(now),
 		Application: l.Application,
-		Level:       level,
+		Level:       l.levelLabel(level),
 		Message:     message,
+		Fields:      l.fields,
+		Tags:        l.tags,
+		level:       level,
+		epoch:       l.Options.EpochTimestamps,
+		ctx:         l.ctx,
+		time:        now,
+	}
+	if l.core != nil {
+		newMessage.Hostname, newMessage.PID = l.hostname, l.pid
+		newMessage.inCallback = l.callbacks.Load() > 0
+	}
+	if l.redacting() {
+		l.redact(&newMessage)
+	}
+	if l.Options.FlattenFields {
+		newMessage.Fields = flattenFields(newMessage.Fields, l.Options.FlattenSlices)
+	}
+	if l.wantsStackTrace(level) {
+		newMessage.Stacktrace = stackTrace()
+	}
+	if l.ctx != nil && l.Options.TraceIDFromContext != nil {
+		newMessage.TraceID, newMessage.SpanID = l.Options.TraceIDFromContext(l.ctx)
+	}
+	return &newMessage
+}
+
+// stamp gives msg the next sequence number when Options.SequenceNumbers is
+// set. Messages dropped before delivery, e.g. by deduplication, get none.
+func (l *VectorLogger) stamp(msg *Message) {
+	if l.Options.SequenceNumbers && l.core != nil {
+		msg.Seq = l.seq.Add(1)
+	}
+}
+
+// SetLevel changes the level of the logger, and of every logger sharing its
+// connection, while it is in use.
+func (l *VectorLogger) SetLevel(level string) error {
+	parsed, err := parseLevel(level, l.Options.LevelAliases)
+	if err != nil {
+		return err
+	}
+	if l.core == nil {
+		return fmt.Errorf("cannot set the level of a logger not created by New")
+	}
+	l.setLevel(parsed)
+	return nil
+}
+
+// GetLevel returns the current level of the logger.
+func (l *VectorLogger) GetLevel() string {
+	if l.core == nil {
+		return l.Level
+	}
+	return l.level.Load().(string)
+}
+
+// shouldLog reports whether a message at level passes the configured level,
+// i.e. whether it is at least as severe. An unknown configured level behaves
+// like INFO. It is the first thing every log method does, so that a call at
+// a disabled level returns without allocating.
+func (l *VectorLogger) shouldLog(level string) bool {
+	if l.core != nil {
+		return severity(level) >= int(l.severity.Load())
+	}
+	configured, ok := levelSeverity[l.Level]
+	if !ok {
+		configured = levelSeverity[INFO]
+	}
+	return levelSeverity[level] >= configured
+}
+
+// severity returns levelSeverity[level], without a map lookup for the level
+// constants.
+func severity(level string) int {
+	switch level {
+	case TRACE:
+		return -1
+	case DEBUG:
+		return 0
+	case INFO:
+		return 1
+	case WARN:
+		return 2
+	case ERROR:
+		return 3
+	case FATAL:
+		return 4
+	}
+	return levelSeverity[level]
+}
+
+// now returns the current time in UTC, from Options.Clock when set.
+func (l *VectorLogger) now() time.Time {
+	if l.Options.Clock != nil {
+		return l.Options.Clock().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// timestamp formats t for the timestamp field.
+func (l *VectorLogger) timestamp(t time.Time) string {
+	if l.Options.EpochTimestamps {
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	if l.Options.TimestampFormat != "" {
+		return t.Format(l.Options.TimestampFormat)
+	}
+	return t.Format(defaultTimestampFormat)
+}
+
+// levelLabel returns the string emitted for level in the level field.
+func (l *VectorLogger) levelLabel(level string) string {
+	if label, ok := l.Options.LevelLabels[level]; ok {
+		return label
+	}
+	if l.Options.LevelFormatter != nil {
+		return l.Options.LevelFormatter(level)
+	}
+	return level
+}
+
+// hasDestination reports whether the logger has anywhere to send messages to.
+// It is false for a zero-value VectorLogger.
+func (l *VectorLogger) hasDestination() bool {
+	if l.Options.Disabled {
+		return false
 	}
-	l.send(&newMessage)
+	return l.usesNetwork() || l.dialsPerMessage() || l.writer() != nil || l.prints() ||
+		len(l.Options.ExtraWriters) > 0 || len(l.Options.ExtraEncoders) > 0
 }
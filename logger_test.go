@@ -0,0 +1,463 @@
+package go_vector_logger
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelFiltering(t *testing.T) {
+	levels := []string{TRACE, DEBUG, INFO, WARN, ERROR, FATAL}
+	for i, configured := range levels {
+		for j, level := range levels {
+			want := j >= i
+			t.Run(configured+"/"+level, func(t *testing.T) {
+				out := &syncBuffer{}
+				logger, err := New("test", configured, "", 0, Options{Writer: out})
+				if err != nil {
+					t.Fatalf("New: %v", err)
+				}
+				if got := logger.shouldLog(level); got != want {
+					t.Errorf("shouldLog(%s) at %s = %v, want %v", level, configured, got, want)
+				}
+				if call, ok := map[string]func(string){
+					TRACE: logger.Trace, DEBUG: logger.Debug, INFO: logger.Info, WARN: logger.Warn, ERROR: logger.Error,
+				}[level]; ok {
+					call("message")
+					if got := strings.Contains(out.String(), `"level":"`+level+`"`); got != want {
+						t.Errorf("%s message emitted at %s = %v, want %v", level, configured, got, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestZeroValueLogger(t *testing.T) {
+	var logger VectorLogger
+	logger.Trace("trace")
+	logger.Debugf("debug %d", 1)
+	logger.Info("info")
+	logger.Warnf("warn %d", 2)
+	logger.Error("error")
+	logger.InfoFields("fields", map[string]interface{}{"key": "value"})
+	logger.With(map[string]interface{}{"key": "value"}).Info("with")
+	logger.WithTag("key", "value").Warn("tag")
+	logger.InfoAs("other", "as")
+	logger.Event(INFO, "event", nil)
+	logger.ErrorErr(errors.New("boom"))
+	if err := logger.TryInfo("try"); err != nil {
+		t.Errorf("TryInfo: %v", err)
+	}
+	if err := logger.SetLevel(DEBUG); err == nil {
+		t.Error("SetLevel succeeded on a logger not created by New")
+	}
+	_ = logger.GetLevel()
+	_ = logger.Stats()
+	if logger.IsConnected() {
+		t.Error("zero value logger reports a connection")
+	}
+	if err := logger.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestNopLogger(t *testing.T) {
+	server := newTestServer(t)
+	disabled, err := New("test", "TRACE", "127.0.0.1", server.port(), Options{Disabled: true, NoExit: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, logger := range []*VectorLogger{NewNop(), disabled} {
+		logger.Trace("trace")
+		logger.Debugf("debug %d", 1)
+		logger.Info("info")
+		logger.Warnf("warn %d", 2)
+		logger.Error("error")
+		logger.InfoFields("fields", map[string]interface{}{"key": "value"})
+		logger.With(map[string]interface{}{"key": "value"}).Info("with")
+		logger.WithTag("key", "value").Warn("tag")
+		logger.InfoAs("other", "as")
+		logger.Event(INFO, "event", nil)
+		logger.ErrorErr(errors.New("boom"))
+		if err := logger.TryInfo("try"); err != nil {
+			t.Errorf("TryInfo: %v", err)
+		}
+		logger.Fatal("fatal")
+		logger.Fatalf("fatal %d", 3)
+		logger.FatalError(errors.New("fatal"))
+		if err := logger.SetLevel(DEBUG); err != nil {
+			t.Errorf("SetLevel: %v", err)
+		}
+		if logger.IsConnected() {
+			t.Error("disabled logger reports a connection")
+		}
+		if err := logger.Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+		if err := logger.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if got := server.conns.Load(); got != 0 {
+		t.Errorf("server accepted %d connections from a disabled logger", got)
+	}
+}
+
+// decodeLines decodes every line of out as a JSON object.
+func decodeLines(t *testing.T, out string) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("cannot decode %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestWithMeasurement(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.WithMeasurement("latency", 123.5, "ms").WithMeasurement("size", 2048, "bytes").Info("request")
+
+	records := decodeLines(t, out.String())
+	fields, _ := records[0]["fields"].(map[string]interface{})
+	want := map[string]interface{}{
+		"latency": map[string]interface{}{"value": 123.5, "unit": "ms"},
+		"size":    map[string]interface{}{"value": 2048.0, "unit": "bytes"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+}
+
+func TestFieldsKeepReservedKeys(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "DEBUG", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	fields := map[string]interface{}{"message": "spoofed", "level": FATAL, "timestamp": "never", "user_id": 7}
+	logger.DebugFields("debug", fields)
+	logger.InfoFields("info", fields)
+	logger.WarnFields("multi\nline", fields)
+	logger.ErrorFields("error", fields)
+
+	if got := strings.Count(out.String(), "\n"); got != 4 {
+		t.Fatalf("emitted %d lines, want one per message", got)
+	}
+	records := decodeLines(t, out.String())
+	for i, want := range [][2]string{{DEBUG, "debug"}, {INFO, "info"}, {WARN, "multi\nline"}, {ERROR, "error"}} {
+		record := records[i]
+		if record["level"] != want[0] || record["message"] != want[1] || record["timestamp"] == "never" {
+			t.Errorf("record %d = %v, want the fields not to replace the reserved keys", i, record)
+		}
+		if got, _ := record["fields"].(map[string]interface{}); got["message"] != "spoofed" || got["user_id"] != 7.0 {
+			t.Errorf("record %d has fields %v", i, got)
+		}
+	}
+}
+
+func TestTagsAndFieldsAreSeparate(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.WithTag("region", "eu").With(map[string]interface{}{"user": "alice"}).Info("tagged")
+
+	records := decodeLines(t, out.String())
+	if got, want := records[0]["tags"], map[string]interface{}{"region": "eu"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+	if got, want := records[0]["fields"], map[string]interface{}{"user": "alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestLevelLabels(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:      out,
+		LevelLabels: map[string]string{INFO: "informational", ERROR: "err"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("filtered")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+
+	var got []interface{}
+	for _, record := range decodeLines(t, out.String()) {
+		got = append(got, record["level"])
+	}
+	if want := []interface{}{"informational", WARN, "err"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("levels = %v, want %v", got, want)
+	}
+}
+
+func TestLevelFormatter(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		LevelFormatter: strings.ToLower,
+		LevelLabels:    map[string]string{ERROR: "Err"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	logger.Debug("filtered")
+	logger.Info("info")
+	logger.Error("error")
+
+	lines := server.waitForLines(t, 2)
+	if len(lines) != 2 || !strings.Contains(lines[0], `"level":"info"`) || !strings.Contains(lines[1], `"level":"Err"`) {
+		t.Errorf("received %q, want the formatted level and the label taking precedence", lines)
+	}
+}
+
+func TestTimestampFormatAndClock(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 678e6, time.FixedZone("CET", 3600)) }
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{"default format", Options{}, `"timestamp":"2024-01-02T02:04:05.67Z"`},
+		{"custom format", Options{TimestampFormat: time.RFC3339Nano}, `"timestamp":"2024-01-02T02:04:05.678Z"`},
+		{"epoch", Options{EpochTimestamps: true}, `"timestamp":1704161045678`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &syncBuffer{}
+			opts := tt.options
+			opts.Writer = out
+			opts.Clock = clock
+			logger, err := New("test", "INFO", "", 0, opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logger.Info("message")
+			if got := out.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("emitted %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "INFO", want: INFO},
+		{input: "trace", want: TRACE},
+		{input: "Debug", want: DEBUG},
+		{input: " wArN ", want: WARN},
+		{input: "Error", want: ERROR},
+		{input: "fatal", want: FATAL},
+		{input: "WARNING", want: WARN},
+		{input: "err", want: ERROR},
+		{input: "Critical", want: FATAL},
+		{input: "", wantErr: true},
+		{input: "LOUD", wantErr: true},
+		{input: "INFO2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %q, %v, want %q (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+		_, err = New("test", tt.input, "", 0, Options{Writer: &syncBuffer{}})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New with level %q: error %v, want error %v", tt.input, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLevelAliases(t *testing.T) {
+	out := &syncBuffer{}
+	aliases := map[string]string{"verbose": "debug", "NOTICE": INFO, "err": WARN}
+	logger, err := New("test", "Verbose", "", 0, Options{Writer: out, LevelAliases: aliases})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := logger.GetLevel(); got != DEBUG {
+		t.Errorf("level = %q, want DEBUG", got)
+	}
+	if err := logger.SetLevel("notice"); err != nil || logger.GetLevel() != INFO {
+		t.Errorf("SetLevel(notice) = %v, level %q, want INFO", err, logger.GetLevel())
+	}
+	if err := logger.SetLevel("ERR"); err != nil || logger.GetLevel() != WARN {
+		t.Errorf("SetLevel(ERR) = %v, level %q, want the custom alias to WARN", err, logger.GetLevel())
+	}
+	if err := logger.SetLevel("warning"); err != nil || logger.GetLevel() != WARN {
+		t.Errorf("SetLevel(warning) = %v, level %q, want the built-in alias to WARN", err, logger.GetLevel())
+	}
+	if err := logger.SetLevel("chatty"); err == nil {
+		t.Error("SetLevel accepted an unknown level")
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel accepted a custom alias")
+	}
+
+	if _, err := New("test", "INFO", "", 0, Options{Writer: out, LevelAliases: map[string]string{"LOUD": "SHOUT"}}); err == nil {
+		t.Error("New accepted an alias to an unknown level")
+	}
+}
+
+func TestSetLevelMidStream(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	child := logger.With(map[string]interface{}{"child": true})
+
+	logger.Debug("hidden 1")
+	if err := logger.SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel: %v", err)
+	}
+	logger.Debug("shown 1")
+	child.Debug("shown 2")
+	if err := child.SetLevel(INFO); err != nil {
+		t.Fatalf("SetLevel: %v", err)
+	}
+	logger.Debug("hidden 2")
+	logger.Info("shown 3")
+	if err := logger.SetLevel("LOUD"); err == nil {
+		t.Error("SetLevel accepted an unknown level")
+	}
+	if got := logger.GetLevel(); got != INFO {
+		t.Errorf("GetLevel = %s after a rejected SetLevel, want INFO", got)
+	}
+
+	var got []interface{}
+	for _, record := range decodeLines(t, out.String()) {
+		got = append(got, record["message"])
+	}
+	if want := []interface{}{"shown 1", "shown 2", "shown 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+}
+
+func TestIncludeHostnameAndPID(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("no hostname: %v", err)
+	}
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, IncludeHostname: true, IncludePID: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("enriched")
+
+	record := decodeLines(t, out.String())[0]
+	if record["hostname"] != hostname || record["pid"] != float64(os.Getpid()) {
+		t.Errorf("hostname = %v, pid = %v, want %s and %d", record["hostname"], record["pid"], hostname, os.Getpid())
+	}
+}
+
+func TestDefaultFieldsPrecedence(t *testing.T) {
+	out := &syncBuffer{}
+	defaults := map[string]interface{}{"env": "prod", "region": "eu", "version": "1"}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, DefaultFields: defaults})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defaults["env"] = "changed after New"
+	logger.With(map[string]interface{}{"region": "us", "user": "alice"}).
+		InfoFields("merged", map[string]interface{}{"version": "2"})
+
+	want := map[string]interface{}{"env": "prod", "region": "us", "user": "alice", "version": "2"}
+	if got := decodeLines(t, out.String())[0]["fields"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestStackTraceLevel(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, StackTraceLevel: ERROR})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("no trace")
+	logger.Error("with trace")
+
+	records := decodeLines(t, out.String())
+	if _, ok := records[0]["stacktrace"]; ok {
+		t.Error("INFO message carries a stack trace")
+	}
+	// The frames of this test belong to the package too and are trimmed with
+	// those of the logger: the trace starts at the caller of the test.
+	trace, _ := records[1]["stacktrace"].(string)
+	if !strings.HasPrefix(trace, "goroutine ") || !strings.Contains(trace, "testing.tRunner") || strings.Contains(trace, "VectorLogger") {
+		t.Errorf("ERROR stack trace = %q, want the frames above the log call", trace)
+	}
+}
+
+func TestMaxMessageBytesTruncates(t *testing.T) {
+	out := &syncBuffer{}
+	const max = 200
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out, MaxMessageBytes: max, NoExit: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info(strings.Repeat("é\"x", 500))
+	logger.Fatal(strings.Repeat("f", 500))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines[0]) > max {
+		t.Errorf("encoded message is %d bytes, over %d", len(lines[0]), max)
+	}
+	records := decodeLines(t, out.String())
+	if message, _ := records[0]["message"].(string); !strings.HasSuffix(message, "…[truncated]") {
+		t.Errorf("message = %q, want it truncated", message)
+	}
+	if message, _ := records[1]["message"].(string); len(message) != 500 {
+		t.Errorf("FATAL message truncated to %d bytes", len(message))
+	}
+}
+
+func TestApplicationOverride(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("default", "DEBUG", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.InfoAs("billing", "charged")
+	logger.Info("plain")
+	logger.ErrorAs("shipping", "lost")
+	logger.Debug("plain again")
+
+	var got [][2]interface{}
+	for _, record := range decodeLines(t, out.String()) {
+		got = append(got, [2]interface{}{record["application"], record["message"]})
+	}
+	want := [][2]interface{}{{"billing", "charged"}, {"default", "plain"}, {"shipping", "lost"}, {"default", "plain again"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
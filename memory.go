@@ -0,0 +1,145 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// shedOrder lists the levels from the first to the last shed when the memory
+// budget is exceeded, see Options.MaxMemoryBytes.
+var shedOrder = []string{TRACE, DEBUG, INFO, WARN, ERROR, FATAL}
+
+// messageOverhead approximates the memory of a queued message besides its
+// strings and fields.
+const messageOverhead = 256
+
+// messageSize estimates the memory held by msg while it waits in the async
+// queue.
+func messageSize(msg *Message) int {
+	size := messageOverhead + len(msg.Timestamp) + len(msg.Application) + len(msg.Level) + len(msg.Message) +
+		len(msg.Event) + len(msg.Stacktrace)
+	for k, v := range msg.Fields {
+		size += len(k) + 16
+		if s, ok := v.(string); ok {
+			size += len(s)
+		}
+	}
+	for k, v := range msg.Tags {
+		size += len(k) + len(v) + 16
+	}
+	return size
+}
+
+// overBudget reports whether the buffered sinks hold more than
+// Options.MaxMemoryBytes.
+func (l *VectorLogger) overBudget() bool {
+	return l.Options.MaxMemoryBytes > 0 && l.memory.Load() > int64(l.Options.MaxMemoryBytes)
+}
+
+// reserveMemory accounts for size more bytes buffered for a record at level,
+// shedding buffered records of lower levels when that exceeds
+// Options.MaxMemoryBytes. It reports false, without reserving anything, when
+// the budget still does not allow for the record, which the caller must then
+// drop; FATAL records are always let through. The caller must hold l.mu.
+func (l *VectorLogger) reserveMemory(size int, level string) bool {
+	l.memory.Add(int64(size))
+	if !l.overBudget() {
+		return true
+	}
+	l.shed(level)
+	if !l.overBudget() || level == FATAL {
+		return true
+	}
+	l.memory.Add(-int64(size))
+	return false
+}
+
+// reserveQueueMemory is reserveMemory for a message entering the async queue,
+// taking l.mu only when the budget is exceeded. The caller must not hold l.mu.
+func (l *VectorLogger) reserveQueueMemory(size int, level string) bool {
+	l.memory.Add(int64(size))
+	if !l.overBudget() {
+		return true
+	}
+	l.memory.Add(-int64(size))
+
+	l.mu.Lock()
+	defer l.unlock()
+	return l.reserveMemory(size, level)
+}
+
+// releaseMemory accounts for size bytes no longer buffered.
+func (l *VectorLogger) releaseMemory(size int) {
+	l.memory.Add(-int64(size))
+}
+
+// shed drops buffered records below level, from the offline buffer first and
+// then from the batch, the least severe and the oldest first, until the
+// buffered sinks fit in Options.MaxMemoryBytes again. Messages waiting in the
+// async queue cannot be reached and are never shed. The caller must hold
+// l.mu.
+func (l *VectorLogger) shed(level string) {
+	dropped := 0
+	for _, shedLevel := range shedOrder {
+		if !l.overBudget() || levelSeverity[shedLevel] >= levelSeverity[level] {
+			break
+		}
+		dropped += l.shedOffline(shedLevel)
+		dropped += l.shedBatch(shedLevel)
+	}
+	if dropped > 0 {
+		l.stats.dropped.Add(uint64(dropped))
+		l.deferError(fmt.Errorf("memory budget of %d bytes exceeded, dropped %d buffered messages", l.Options.MaxMemoryBytes, dropped))
+	}
+}
+
+// shedOffline drops entries at level from the offline buffer, oldest first,
+// while over the budget, and returns the number of messages dropped. The
+// caller must hold l.mu.
+func (l *VectorLogger) shedOffline(level string) int {
+	dropped := 0
+	kept := l.offline[:0]
+	for _, entry := range l.offline {
+		if entry.level == level && l.overBudget() {
+			l.releaseMemory(len(entry.data))
+			dropped += entry.messages
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	for i := len(kept); i < len(l.offline); i++ {
+		l.offline[i] = offlineEntry{}
+	}
+	l.offline = kept
+	return dropped
+}
+
+// shedBatch drops messages at level from the batch, oldest first, while over
+// the budget, and returns the number of messages dropped. The caller must
+// hold l.mu.
+func (l *VectorLogger) shedBatch(level string) int {
+	var data bytes.Buffer
+	dropped, start := 0, 0
+	kept := l.batched[:0]
+	for _, record := range l.batched {
+		encoded := l.batch.Bytes()[start : start+record.size]
+		start += record.size
+		if record.msg.level == level && l.overBudget() {
+			l.releaseMemory(record.size)
+			dropped++
+			continue
+		}
+		data.Write(encoded)
+		kept = append(kept, record)
+	}
+	if dropped == 0 {
+		return 0
+	}
+	for i := len(kept); i < len(l.batched); i++ {
+		l.batched[i] = batchRecord{}
+	}
+	l.batched = kept
+	l.batch.Reset()
+	l.batch.Write(data.Bytes())
+	return dropped
+}
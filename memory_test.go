@@ -0,0 +1,139 @@
+package go_vector_logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxMemoryBytesShedsOfflineBufferBySeverity(t *testing.T) {
+	const budget = 4000
+	logger, err := New("test", "DEBUG", "127.0.0.1", closedPort(t), Options{
+		LazyConnect:         true,
+		OfflineBufferSize:   10000,
+		MaxMemoryBytes:      budget,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		logger.Debugf("debug %d", i)
+		if i%20 == 0 {
+			logger.Errorf("error %d", i)
+		}
+		if got := logger.memory.Load(); got > budget {
+			t.Fatalf("%d bytes buffered, over the budget of %d", got, budget)
+		}
+	}
+
+	logger.mu.Lock()
+	errors, debugs := 0, 0
+	for _, entry := range logger.offline {
+		switch {
+		case strings.Contains(string(entry.data), `"error `):
+			errors++
+		case strings.Contains(string(entry.data), `"debug `):
+			debugs++
+		}
+	}
+	logger.mu.Unlock()
+	if errors != 10 {
+		t.Errorf("%d ERROR messages kept, want 10", errors)
+	}
+	if debugs == 0 || debugs == 200 {
+		t.Errorf("%d DEBUG messages kept, want some shed", debugs)
+	}
+	if got := logger.Stats().Dropped; got != uint64(200-debugs) {
+		t.Errorf("Dropped = %d, want %d", got, 200-debugs)
+	}
+	_ = logger.CloseWithTimeout(time.Second)
+}
+
+func TestMaxMemoryBytesShedsBatch(t *testing.T) {
+	const budget = 3000
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		BatchSize:           1000,
+		BatchInterval:       time.Hour,
+		MaxMemoryBytes:      budget,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		logger.Infof("info %d", i)
+		if i%10 == 0 {
+			logger.Warnf("warn %d", i)
+		}
+		if got := logger.memory.Load(); got > budget {
+			t.Fatalf("%d bytes buffered, over the budget of %d", got, budget)
+		}
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	dropped := int(logger.Stats().Dropped)
+	lines := server.waitForLines(t, 110-dropped)
+	if got := countContaining(lines, `"warn `); got != 10 {
+		t.Errorf("received %d WARN messages, want 10", got)
+	}
+	infos := countContaining(lines, `"info `)
+	if infos == 0 || infos == 100 {
+		t.Errorf("received %d INFO messages, want some shed", infos)
+	}
+	if got := logger.Stats().Dropped; got != uint64(100-infos) {
+		t.Errorf("Dropped = %d, want %d", got, 100-infos)
+	}
+	if got := logger.memory.Load(); got != 0 {
+		t.Errorf("%d bytes still accounted after Close", got)
+	}
+}
+
+func TestMaxMemoryBytesBoundsAsyncQueue(t *testing.T) {
+	const budget = 5000
+	out := &gatedWriter{open: make(chan struct{})}
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:              out,
+		Async:               true,
+		BufferSize:          1000,
+		MaxMemoryBytes:      budget,
+		NoExit:              true,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		logger.Infof("info %d", i)
+		if got := logger.memory.Load(); got > budget {
+			t.Fatalf("%d bytes buffered, over the budget of %d", got, budget)
+		}
+	}
+	if logger.Stats().Dropped == 0 {
+		t.Error("nothing dropped past the budget")
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Fatal("fatal")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(out.open)
+	<-done
+
+	if !strings.Contains(out.String(), `"fatal"`) {
+		t.Error("FATAL message dropped past the budget")
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := logger.memory.Load(); got != 0 {
+		t.Errorf("%d bytes still accounted after Close", got)
+	}
+}
@@ -0,0 +1,317 @@
+package go_vector_logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// MultiLogger sends every message to several loggers, e.g. a primary and a
+// backup Vector cluster. Each logger applies its own level, options and
+// fields. Its methods call the loggers' internals directly rather than their
+// public methods, so that the caller recorded with Options.IncludeCaller is
+// the application code.
+type MultiLogger struct {
+	loggers []*VectorLogger
+}
+
+// NewMultiLogger returns a MultiLogger sending to every logger of loggers.
+func NewMultiLogger(loggers ...*VectorLogger) *MultiLogger {
+	return &MultiLogger{loggers: loggers}
+}
+
+// Loggers returns the loggers m sends to.
+func (m *MultiLogger) Loggers() []*VectorLogger {
+	return append([]*VectorLogger(nil), m.loggers...)
+}
+
+// With returns a MultiLogger whose loggers all carry fields, see
+// VectorLogger.With.
+func (m *MultiLogger) With(fields map[string]interface{}) *MultiLogger {
+	children := make([]*VectorLogger, len(m.loggers))
+	for i, l := range m.loggers {
+		children[i] = l.withFields(fields)
+	}
+	return &MultiLogger{loggers: children}
+}
+
+// enabled reports whether any of the loggers logs messages at level, so that
+// the ...f methods format only messages that are sent.
+func (m *MultiLogger) enabled(level string) bool {
+	for _, l := range m.loggers {
+		if l.shouldLog(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracef logs a trace message with a formatted string.
+func (m *MultiLogger) Tracef(format string, v ...interface{}) {
+	if !m.enabled(TRACE) {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+	for _, l := range m.loggers {
+		if l.shouldLog(TRACE) {
+			l.sendMessage(message, TRACE)
+		}
+	}
+}
+
+// Trace logs a trace message.
+func (m *MultiLogger) Trace(message string) {
+	for _, l := range m.loggers {
+		if l.shouldLog(TRACE) {
+			l.sendMessage(message, TRACE)
+		}
+	}
+}
+
+// Debugf logs a debug message with a formatted string.
+func (m *MultiLogger) Debugf(format string, v ...interface{}) {
+	if !m.enabled(DEBUG) {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+	for _, l := range m.loggers {
+		if l.shouldLog(DEBUG) {
+			l.sendMessage(message, DEBUG)
+		}
+	}
+}
+
+// Debug logs a debug message.
+func (m *MultiLogger) Debug(message string) {
+	for _, l := range m.loggers {
+		if l.shouldLog(DEBUG) {
+			l.sendMessage(message, DEBUG)
+		}
+	}
+}
+
+// Infof logs an info message with a formatted string.
+func (m *MultiLogger) Infof(format string, v ...interface{}) {
+	if !m.enabled(INFO) {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+	for _, l := range m.loggers {
+		if l.shouldLog(INFO) {
+			l.sendMessage(message, INFO)
+		}
+	}
+}
+
+// Info logs an info message.
+func (m *MultiLogger) Info(message string) {
+	for _, l := range m.loggers {
+		if l.shouldLog(INFO) {
+			l.sendMessage(message, INFO)
+		}
+	}
+}
+
+// Warnf logs a warning message with a formatted string.
+func (m *MultiLogger) Warnf(format string, v ...interface{}) {
+	if !m.enabled(WARN) {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+	for _, l := range m.loggers {
+		if l.shouldLog(WARN) {
+			l.sendMessage(message, WARN)
+		}
+	}
+}
+
+// Warn logs a warning message.
+func (m *MultiLogger) Warn(message string) {
+	for _, l := range m.loggers {
+		if l.shouldLog(WARN) {
+			l.sendMessage(message, WARN)
+		}
+	}
+}
+
+// Errorf logs an error message with a formatted string.
+func (m *MultiLogger) Errorf(format string, v ...interface{}) {
+	if !m.enabled(ERROR) {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+	for _, l := range m.loggers {
+		if l.shouldLog(ERROR) {
+			l.sendMessage(message, ERROR)
+		}
+	}
+}
+
+// Error logs an error message.
+func (m *MultiLogger) Error(message string) {
+	for _, l := range m.loggers {
+		if l.shouldLog(ERROR) {
+			l.sendMessage(message, ERROR)
+		}
+	}
+}
+
+// DebugFields logs a debug message with structured fields.
+func (m *MultiLogger) DebugFields(message string, fields map[string]interface{}) {
+	for _, l := range m.loggers {
+		if l.shouldLog(DEBUG) {
+			l.withFields(fields).sendMessage(message, DEBUG)
+		}
+	}
+}
+
+// InfoFields logs an info message with structured fields.
+func (m *MultiLogger) InfoFields(message string, fields map[string]interface{}) {
+	for _, l := range m.loggers {
+		if l.shouldLog(INFO) {
+			l.withFields(fields).sendMessage(message, INFO)
+		}
+	}
+}
+
+// WarnFields logs a warning message with structured fields.
+func (m *MultiLogger) WarnFields(message string, fields map[string]interface{}) {
+	for _, l := range m.loggers {
+		if l.shouldLog(WARN) {
+			l.withFields(fields).sendMessage(message, WARN)
+		}
+	}
+}
+
+// ErrorFields logs an error message with structured fields.
+func (m *MultiLogger) ErrorFields(message string, fields map[string]interface{}) {
+	for _, l := range m.loggers {
+		if l.shouldLog(ERROR) {
+			l.withFields(fields).sendMessage(message, ERROR)
+		}
+	}
+}
+
+// LogErr logs err at the error level and returns it unchanged, see
+// VectorLogger.LogErr.
+func (m *MultiLogger) LogErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, l := range m.loggers {
+		if l.shouldLog(ERROR) {
+			l.withFields(l.errorFields(err)).sendMessage(err.Error(), ERROR)
+		}
+	}
+	return err
+}
+
+// ErrorErr logs err at the error level, see VectorLogger.ErrorErr.
+func (m *MultiLogger) ErrorErr(err error) {
+	if err == nil {
+		return
+	}
+	for _, l := range m.loggers {
+		if l.shouldLog(ERROR) {
+			l.withFields(l.errorFields(err)).sendMessage(err.Error(), ERROR)
+		}
+	}
+}
+
+// Event logs the event name at level with attrs to every logger. A logger
+// not knowing level drops the event and reports it, see VectorLogger.Event.
+func (m *MultiLogger) Event(level, name string, attrs map[string]interface{}) {
+	for _, l := range m.loggers {
+		parsed, err := parseLevel(level, l.Options.LevelAliases)
+		if err != nil {
+			l.reportError(fmt.Errorf("cannot log event %q: %w", name, err))
+			continue
+		}
+		if l.shouldLog(parsed) {
+			l.withFields(attrs).sendEvent(name, parsed)
+		}
+	}
+}
+
+// Fatalf logs a fatal message with a formatted string to every logger,
+// flushes them and exits, unless every logger has Options.NoExit set.
+func (m *MultiLogger) Fatalf(format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	for _, l := range m.loggers {
+		l.sendMessage(message, FATAL)
+	}
+	_ = m.Flush()
+	m.exit()
+}
+
+// Fatal logs a fatal message to every logger, flushes them and exits, unless
+// every logger has Options.NoExit set.
+func (m *MultiLogger) Fatal(message string) {
+	for _, l := range m.loggers {
+		l.sendMessage(message, FATAL)
+	}
+	_ = m.Flush()
+	m.exit()
+}
+
+// FatalError logs an error at the fatal level to every logger, flushes them
+// and exits, unless every logger has Options.NoExit set. A nil error is
+// ignored and does not exit.
+func (m *MultiLogger) FatalError(message error) {
+	if message == nil {
+		return
+	}
+	for _, l := range m.loggers {
+		l.withFields(l.errorFields(message)).sendMessage(message.Error(), FATAL)
+	}
+	_ = m.Flush()
+	m.exit()
+}
+
+// exit ends the process after a fatal message, unless every logger has
+// Options.NoExit set.
+func (m *MultiLogger) exit() {
+	for _, l := range m.loggers {
+		if !l.Options.NoExit {
+			os.Exit(1)
+		}
+	}
+	if len(m.loggers) == 0 {
+		os.Exit(1)
+	}
+}
+
+// Flush flushes every logger and returns their errors joined.
+func (m *MultiLogger) Flush() error {
+	var errs []error
+	for _, l := range m.loggers {
+		if err := l.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every logger and returns their errors joined.
+func (m *MultiLogger) Close() error {
+	var errs []error
+	for _, l := range m.loggers {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown shuts every logger down within ctx and returns their errors
+// joined.
+func (m *MultiLogger) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, l := range m.loggers {
+		if err := l.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// countingStringer counts how often it is formatted.
+type countingStringer struct{ calls int }
+
+func (s *countingStringer) String() string {
+	s.calls++
+	return "formatted"
+}
+
+func TestMultiLoggerFormatsOnlyEnabledLevels(t *testing.T) {
+	var warnOut, infoOut bytes.Buffer
+	warn, err := New("test", "WARN", "", 0, Options{Writer: &warnOut})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	info, err := New("test", "INFO", "", 0, Options{Writer: &infoOut})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	m := NewMultiLogger(warn, info)
+
+	arg := &countingStringer{}
+	m.Tracef("trace %v", arg)
+	m.Debugf("debug %v", arg)
+	if arg.calls != 0 {
+		t.Errorf("disabled levels formatted their arguments %d times", arg.calls)
+	}
+
+	m.Infof("info %v", arg)
+	if arg.calls != 1 {
+		t.Errorf("Infof formatted its arguments %d times, want 1", arg.calls)
+	}
+	if warnOut.Len() != 0 {
+		t.Errorf("WARN logger received %q", warnOut.String())
+	}
+	if !strings.Contains(infoOut.String(), "info formatted") {
+		t.Errorf("INFO logger received %q", infoOut.String())
+	}
+}
+
+func TestMultiLoggerFansOut(t *testing.T) {
+	first, second := newTestServer(t), newTestServer(t)
+	var loggers []*VectorLogger
+	for _, server := range []*testServer{first, second} {
+		logger, err := New("test", "INFO", "127.0.0.1", server.port())
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer logger.Close()
+		loggers = append(loggers, logger)
+	}
+	multi := NewMultiLogger(loggers...)
+	multi.Info("one")
+	multi.With(map[string]interface{}{"key": "value"}).Warnf("two %d", 2)
+	multi.Debug("filtered")
+
+	for i, server := range []*testServer{first, second} {
+		lines := server.waitForLines(t, 2)
+		if len(lines) != 2 || countContaining(lines, `"message":"one"`) != 1 || countContaining(lines, `"message":"two 2","fields":{"key":"value"}`) != 1 {
+			t.Errorf("server %d received %q", i, lines)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package go_vector_logger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultBlockTimeout bounds the wait for a connection under the Block policy.
+const defaultBlockTimeout = 5 * time.Second
+
+// blockRetryInterval is the pause between two connection attempts under the
+// Block policy when no reconnect backoff is configured.
+const blockRetryInterval = 100 * time.Millisecond
+
+// DropPolicy selects what happens to a message logged while there is no
+// connection to Vector, see Options.DropPolicy.
+type DropPolicy int
+
+const (
+	// DropOldest keeps messages in the offline buffer, when one is enabled,
+	// and drops the oldest buffered message once it is full (default).
+	DropOldest DropPolicy = iota
+	// DropNewest keeps the messages already in the offline buffer and drops
+	// new ones once it is full; without a buffer they are dropped at once.
+	DropNewest
+	// Block waits for the connection to come back, up to
+	// Options.BlockTimeout, before falling back to DropOldest.
+	Block
+)
+
+// offlineEntry is an encoded write kept in the offline buffer.
+type offlineEntry struct {
+	data     []byte
+	messages int    // Number of messages in data (more than one for a batch).
+	level    string // Most severe level of the messages, see Options.MaxMemoryBytes.
+}
+
+// keepOffline reports whether data that could not be sent because of err
+// should go to the offline buffer instead of being reported as failed.
+func (l *VectorLogger) keepOffline(err error) bool {
+	if l.Options.OfflineBufferSize > 0 {
+		return true
+	}
+	return l.Options.QueueDuringBackoff && errors.Is(err, ErrReconnectBackoff)
+}
+
+// offlineBufferSize returns the capacity of the offline buffer.
+func (l *VectorLogger) offlineBufferSize() int {
+	if l.Options.OfflineBufferSize > 0 {
+		return l.Options.OfflineBufferSize
+	}
+	return defaultBufferSize
+}
+
+// bufferOffline appends data holding the given number of messages, at level
+// or below, to the offline buffer. When it is full, the oldest entry is
+// dropped, or data itself under the DropNewest policy; data is dropped as
+// well when Options.MaxMemoryBytes leaves no room for it. The caller must
+// hold l.mu.
+func (l *VectorLogger) bufferOffline(data []byte, messages int, level string) {
+	if len(l.offline) >= l.offlineBufferSize() {
+		if l.Options.DropPolicy == DropNewest {
+			l.deferError(fmt.Errorf("offline buffer is full, dropping the newest message"))
+			l.stats.dropped.Add(uint64(messages))
+			return
+		}
+		l.deferError(fmt.Errorf("offline buffer is full, dropping the oldest message"))
+		l.stats.dropped.Add(uint64(l.offline[0].messages))
+		l.releaseMemory(len(l.offline[0].data))
+		l.offline = l.offline[1:]
+	}
+	if !l.reserveMemory(len(data), level) {
+		l.deferError(fmt.Errorf("memory budget of %d bytes exceeded, dropping %d messages", l.Options.MaxMemoryBytes, messages))
+		l.stats.dropped.Add(uint64(messages))
+		return
+	}
+	l.offline = append(l.offline, offlineEntry{data: append([]byte(nil), data...), messages: messages, level: level})
+}
+
+// blockTimeout returns how long the Block policy waits for a connection.
+func (l *VectorLogger) blockTimeout() time.Duration {
+	if l.Options.BlockTimeout > 0 {
+		return l.Options.BlockTimeout
+	}
+	return defaultBlockTimeout
+}
+
+// waitForConnection retries the connection to Vector until it succeeds or
+// the Block policy timeout expires, honoring the reconnect backoff, and
+// returns the last error. It returns at once when Options.MaxReconnectAttempts
+// is exhausted, since no retry can succeed then. The caller must hold l.mu, so
+// every other message waits as well.
+func (l *VectorLogger) waitForConnection(err error) error {
+	deadline := time.Now().Add(l.blockTimeout())
+	for {
+		if l.reconnectsExhausted() {
+			return err
+		}
+		wait := blockRetryInterval
+		if next := l.backoff.NextAttempt; !next.IsZero() {
+			wait = time.Until(next)
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return err
+		}
+		if wait > remaining {
+			wait = remaining
+		}
+		time.Sleep(wait)
+		if err = l.reconnect(); err == nil {
+			return nil
+		}
+	}
+}
+
+// flushOffline writes the offline buffer, in order, on the current connection
+// or over HTTP. The caller must hold l.mu and, unless Protocol is "http",
+// l.conn must be set.
+func (l *VectorLogger) flushOffline() error {
+	for len(l.offline) > 0 {
+		if l.usesHTTP() {
+			if err := l.post(l.offline[0].data, l.offline[0].messages, time.Time{}); err != nil {
+				return err
+			}
+		} else if _, err := l.writeWithDeadline(l.offline[0].data, time.Time{}); err != nil {
+			l.loseConnection()
+			return fmt.Errorf("cannot send data to vector: %w", err)
+		}
+		l.countWritten(l.offline[0].messages)
+		l.releaseMemory(len(l.offline[0].data))
+		l.offline = l.offline[1:]
+	}
+	l.offline = nil
+	return nil
+}
+
+// flushOfflineOnClose makes a last attempt to deliver the offline buffer and
+// reports what could not be delivered. The caller must hold l.mu.
+func (l *VectorLogger) flushOfflineOnClose() {
+	if l.conn == nil && !l.usesHTTP() {
+		if err := l.establishConnection(); err != nil {
+			l.dropOffline(err)
+			return
+		}
+	}
+	if err := l.flushOffline(); err != nil {
+		l.dropOffline(err)
+	}
+}
+
+// dropOffline discards the offline buffer after a final delivery attempt
+// failed with err. The caller must hold l.mu.
+func (l *VectorLogger) dropOffline(err error) {
+	messages := 0
+	for _, entry := range l.offline {
+		messages += entry.messages
+		l.releaseMemory(len(entry.data))
+	}
+	l.deferError(fmt.Errorf("dropping %d buffered messages: %w", messages, err))
+	l.stats.dropped.Add(uint64(messages))
+	l.offline = nil
+}
@@ -0,0 +1,150 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlockReturnsOnceReconnectsAreExhausted(t *testing.T) {
+	logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{
+		LazyConnect:          true,
+		DropPolicy:           Block,
+		BlockTimeout:         time.Minute,
+		InitialBackoff:       time.Millisecond,
+		MaxBackoff:           time.Millisecond,
+		MaxReconnectAttempts: 3,
+		InternalErrorWriter:  &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	start := time.Now()
+	if err := logger.TryInfo("first"); err == nil {
+		t.Fatal("TryInfo succeeded with Vector down")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("first message blocked for %v after the reconnect attempts ran out", elapsed)
+	}
+	if got := logger.BackoffState().Attempts; got != 3 {
+		t.Errorf("Attempts = %d, want 3", got)
+	}
+
+	start = time.Now()
+	if err := logger.TryInfo("second"); err == nil {
+		t.Fatal("TryInfo succeeded with Vector down")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("second message blocked for %v with no reconnect attempt left", elapsed)
+	}
+}
+
+func TestOfflineBufferReplaysInOrder(t *testing.T) {
+	port := closedPort(t)
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{
+		LazyConnect:         true,
+		OfflineBufferSize:   100,
+		InternalErrorWriter: &syncBuffer{},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 5; i++ {
+		logger.Infof("offline %d", i)
+	}
+	server := newTestServerOn(t, port)
+	logger.Info("online")
+
+	lines := server.waitForLines(t, 6)
+	if len(lines) != 6 {
+		t.Fatalf("received %d lines, want 6", len(lines))
+	}
+	for i, line := range lines {
+		want := "online"
+		if i < 5 {
+			want = fmt.Sprintf("offline %d", i)
+		}
+		if !strings.Contains(line, `"message":"`+want+`"`) {
+			t.Errorf("line %d = %s, want message %q", i, line, want)
+		}
+	}
+}
+
+func TestDropPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DropPolicy
+		want   []string
+	}{
+		{"drop oldest", DropOldest, []string{"down 2", "down 3", "up"}},
+		{"drop newest", DropNewest, []string{"down 0", "down 1", "up"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := closedPort(t)
+			logger, err := New("test", "INFO", "127.0.0.1", port, Options{
+				LazyConnect:         true,
+				DropPolicy:          tt.policy,
+				OfflineBufferSize:   2,
+				InternalErrorWriter: &syncBuffer{},
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer logger.Close()
+			for i := 0; i < 4; i++ {
+				logger.Infof("down %d", i)
+			}
+			server := newTestServerOn(t, port)
+			logger.Info("up")
+
+			lines := server.waitForLines(t, len(tt.want))
+			if len(lines) != len(tt.want) {
+				t.Fatalf("received %d lines, want %d", len(lines), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(lines[i], `"message":"`+want+`"`) {
+					t.Errorf("line %d = %s, want message %q", i, lines[i], want)
+				}
+			}
+			if dropped := logger.Stats().Dropped; dropped != 2 {
+				t.Errorf("Dropped = %d, want 2", dropped)
+			}
+		})
+	}
+
+	t.Run("block", func(t *testing.T) {
+		port := closedPort(t)
+		logger, err := New("test", "INFO", "127.0.0.1", port, Options{
+			LazyConnect:         true,
+			DropPolicy:          Block,
+			BlockTimeout:        10 * time.Second,
+			InternalErrorWriter: &syncBuffer{},
+		})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer logger.Close()
+
+		servers := make(chan *testServer, 1)
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			servers <- newTestServerOn(t, port)
+		}()
+		start := time.Now()
+		if err := logger.TryInfo("waited"); err != nil {
+			t.Fatalf("TryInfo: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+			t.Errorf("TryInfo returned after %v, before Vector was up", elapsed)
+		}
+		if lines := (<-servers).waitForLines(t, 1); countContaining(lines, "waited") != 1 {
+			t.Errorf("received %q", lines)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStderr runs f with os.Stderr redirected and returns what it wrote.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stderr, f)
+}
+
+// captureStdout runs f with os.Stdout redirected and returns what it wrote.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stdout, f)
+}
+
+// captureFile runs f with *file redirected to a pipe and returns what it
+// wrote.
+func captureFile(t *testing.T, file **os.File, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	saved := *file
+	*file = w
+	defer func() { *file = saved }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	_ = w.Close()
+	return <-done
+}
+
+func TestMirrorToStderr(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    func() Options
+		wantMirror int
+		wantStdout int
+	}{
+		{"not printed", func() Options { return Options{} }, 1, 0},
+		{"printed to a console writer", func() Options { return Options{ConsoleWriter: &bytes.Buffer{}} }, 0, 0},
+		{"printed to stdout", func() Options { return Options{AlsoPrintMessages: true} }, 0, 1},
+		{"printed to stderr", func() Options { return Options{ConsoleWriter: os.Stderr} }, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var console *bytes.Buffer
+			var stdout string
+			got := captureStderr(t, func() {
+				stdout = captureStdout(t, func() {
+					opts := tt.options()
+					if buf, ok := opts.ConsoleWriter.(*bytes.Buffer); ok {
+						console = buf
+					}
+					opts.Writer = io.Discard
+					opts.MirrorToStderrLevel = WARN
+					logger, err := New("test", "INFO", "", 0, opts)
+					if err != nil {
+						t.Fatalf("New: %v", err)
+					}
+					logger.Info("info message")
+					logger.Warn("warn message")
+				})
+			})
+			if n := strings.Count(got, "warn message"); n != tt.wantMirror {
+				t.Errorf("warning written %d times to stderr, want %d:\n%s", n, tt.wantMirror, got)
+			}
+			if n := strings.Count(stdout, "warn message"); n != tt.wantStdout {
+				t.Errorf("warning written %d times to stdout, want %d:\n%s", n, tt.wantStdout, stdout)
+			}
+			if tt.options().ConsoleWriter != os.Stderr && strings.Contains(got, "info message") {
+				t.Errorf("info message mirrored to stderr:\n%s", got)
+			}
+			if console != nil {
+				if n := strings.Count(console.String(), "message"); n != 2 {
+					t.Errorf("console received %d messages, want 2", n)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintWithoutNew(t *testing.T) {
+	console := &bytes.Buffer{}
+	var stderr string
+	stdout := captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			logger := &VectorLogger{Application: "a", Level: "INFO", Options: Options{ConsoleWriter: console}}
+			logger.Info("to the console")
+			mirrored := &VectorLogger{Application: "a", Level: "INFO", Options: Options{Writer: io.Discard, MirrorToStderrLevel: WARN}}
+			mirrored.Info("info message")
+			mirrored.Warn("warn message")
+		})
+	})
+	if !strings.Contains(console.String(), "to the console") {
+		t.Errorf("console received %q", console.String())
+	}
+	if strings.Count(stderr, "warn message") != 1 || strings.Contains(stderr, "info message") {
+		t.Errorf("stderr received %q, want the warning only", stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout received %q, want nothing", stdout)
+	}
+}
+
+func TestConsoleFormat(t *testing.T) {
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:            io.Discard,
+		AlsoPrintMessages: true,
+		ConsoleFormat: func(msg *Message) string {
+			return "[" + msg.Level + "] " + msg.Application + ": " + msg.Message
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	got := captureStdout(t, func() {
+		logger.Info("first")
+		logger.Warn("second")
+	})
+	if want := "[INFO] test: first\n[WARN] test: second\n"; got != want {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+}
+
+func TestConsoleWriter(t *testing.T) {
+	var console bytes.Buffer
+	logger, err := New("test", "INFO", "", 0, Options{
+		Writer:            io.Discard,
+		AlsoPrintMessages: true,
+		ConsoleWriter:     &console,
+		Clock:             func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	stdout := captureStdout(t, func() { logger.Info("to the console writer") })
+	if stdout != "" {
+		t.Errorf("printed %q on stdout", stdout)
+	}
+	if want := "2024-01-02T03:04:05.00Z |  INFO | to the console writer\n"; console.String() != want {
+		t.Errorf("console received %q, want %q", console.String(), want)
+	}
+}
+
+func TestColor(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    bool
+	}{
+		{"disabled", Options{}, false},
+		{"not a terminal", Options{Color: true}, false},
+		{"forced", Options{ForceColor: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var console bytes.Buffer
+			opts := tt.options
+			opts.Writer = io.Discard
+			opts.ConsoleWriter = &console
+			logger, err := New("test", "INFO", "", 0, opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logger.Warn("warn")
+			logger.Error("error")
+
+			out := console.String()
+			colored := strings.Contains(out, "\x1b[33m WARN\x1b[0m") && strings.Contains(out, "\x1b[31mERROR\x1b[0m")
+			if colored != tt.want || (!tt.want && strings.Contains(out, "\x1b[")) {
+				t.Errorf("printed %q, want colors %v", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalErrorWriter(t *testing.T) {
+	internal := &syncBuffer{}
+	logger, err := New("test", "INFO", "127.0.0.1", closedPort(t), Options{LazyConnect: true, InternalErrorWriter: internal})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	stderr := captureStderr(t, func() { logger.Info("refused") })
+
+	if stderr != "" {
+		t.Errorf("printed %q on stderr", stderr)
+	}
+	if got := internal.String(); !strings.Contains(got, "[ERROR]") || !strings.Contains(got, "connection refused") {
+		t.Errorf("InternalErrorWriter received %q", got)
+	}
+}
+
+func TestQuietByDefault(t *testing.T) {
+	server := newTestServer(t)
+	var stderr string
+	stdout := captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{IdleCheckInterval: 10 * time.Millisecond})
+			if err != nil {
+				t.Errorf("New: %v", err)
+				return
+			}
+			logger.SetTimeoutDuration(20 * time.Millisecond)
+			logger.Info("message")
+			time.Sleep(100 * time.Millisecond) // Let the manager close the idle connection.
+			logger.Info("reconnected")
+			_ = logger.Close()
+		})
+	})
+	if stdout != "" || stderr != "" {
+		t.Errorf("printed %q on stdout and %q on stderr", stdout, stderr)
+	}
+}
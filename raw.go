@@ -0,0 +1,95 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// WriteRaw logs every text of messages as a message at level, in order, with
+// a single network write where possible: the messages are encoded together
+// and written at once, after the pending batch. Like TryInfo it bypasses the
+// async queue and returns the delivery error; messages refused by
+// Options.RateLimit or Options.SampleRate are skipped, and deduplication does
+// not apply. An unknown level is an error and nothing is logged.
+func (l *VectorLogger) WriteRaw(level string, messages []string) error {
+	level, err := parseLevel(level, l.Options.LevelAliases)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 || !l.shouldLog(level) || !l.hasDestination() {
+		return nil
+	}
+
+	var c caller
+	var withCaller bool
+	if l.Options.IncludeCaller {
+		c, withCaller = captureCaller(2)
+	}
+	msgs := make([]*Message, 0, len(messages))
+	for _, message := range messages {
+		if !l.admit(level) {
+			continue
+		}
+		msg := l.buildMessage(message, level)
+		l.stamp(msg)
+		if withCaller {
+			msg.File, msg.Line, msg.Function = c.File, c.Line, c.Function
+		}
+		l.print(msg)
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	err = l.sendAllToVector(msgs)
+	for _, msg := range msgs {
+		if err != nil {
+			l.handleFailure(msg, err)
+		}
+		l.writeExtraSinks(msg)
+	}
+	return err
+}
+
+// sendAllToVector sends the encoded msgs to the configured writer or to
+// Vector in a single write and returns the delivery error, if any.
+func (l *VectorLogger) sendAllToVector(msgs []*Message) error {
+	if l.writer() == nil && !l.usesNetwork() && !l.dialsPerMessage() {
+		return nil
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	for _, msg := range msgs {
+		if err := l.encode(buf, msg); err != nil {
+			return err
+		}
+	}
+	if l.dialsPerMessage() {
+		return l.writeOwnConn(buf.Bytes())
+	}
+
+	if l.core != nil {
+		l.mu.Lock()
+		defer l.unlock()
+	}
+	if writer := l.writer(); writer != nil {
+		if _, err := buf.WriteTo(writer); err != nil {
+			return fmt.Errorf("cannot send data to vector: %w", err)
+		}
+		if l.core != nil {
+			l.stats.sent.Add(uint64(len(msgs)))
+			l.setLastError(nil)
+		}
+		return nil
+	}
+	if err := l.flushBatch(); err != nil {
+		return err
+	}
+	err := l.writeToConn(buf.Bytes(), len(msgs), msgs[0].level, time.Time{})
+	if err == nil && msgs[0].level == FATAL {
+		err = l.flushFatal()
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnknownLevelsAreRejected(t *testing.T) {
+	var out bytes.Buffer
+	internal := &syncBuffer{}
+	logger, err := New("test", "TRACE", "", 0, Options{
+		Writer:              &out,
+		LevelAliases:        map[string]string{"notice": INFO},
+		InternalErrorWriter: internal,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := logger.WriteRaw("verbose", []string{"raw"}); err == nil {
+		t.Error("WriteRaw accepted an unknown level")
+	}
+	logger.Event("verbose", "user.signup", nil)
+	_, _ = logger.Writer("verbose").Write([]byte("written\n"))
+	if out.Len() != 0 {
+		t.Errorf("messages at an unknown level were logged: %q", out.String())
+	}
+	if got := strings.Count(internal.String(), `unknown log level "verbose"`); got != 2 {
+		t.Errorf("reported %d unknown levels, want 2:\n%s", got, internal.String())
+	}
+
+	if err := logger.WriteRaw("notice", []string{"raw"}); err != nil {
+		t.Errorf("WriteRaw at an alias: %v", err)
+	}
+	logger.Event("warn", "user.signup", nil)
+	_, _ = logger.Writer("Error").Write([]byte("written\n"))
+	for _, want := range []string{`"level":"INFO","message":"raw"`, `"level":"WARN","message":"user.signup"`, `"level":"ERROR","message":"written"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %s:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteRawDeliversInOrder(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	messages := []string{"alpha", "bravo", "charlie", "delta"}
+	if err := logger.WriteRaw("warn", messages); err != nil {
+		t.Fatalf("WriteRaw: %v", err)
+	}
+	lines := server.waitForLines(t, len(messages))
+	if len(lines) != len(messages) {
+		t.Fatalf("received %d lines, want %d", len(lines), len(messages))
+	}
+	for i, want := range messages {
+		if !strings.Contains(lines[i], `"level":"WARN","message":"`+want+`"`) {
+			t.Errorf("line %d = %s, want message %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestWriteRawWithoutNew(t *testing.T) {
+	out := &syncBuffer{}
+	logger := &VectorLogger{Application: "a", Level: "INFO", Options: Options{Writer: out}}
+	if err := logger.WriteRaw(WARN, []string{"first", "second"}); err != nil {
+		t.Fatalf("WriteRaw: %v", err)
+	}
+	if err := logger.WriteRaw(DEBUG, []string{"filtered"}); err != nil {
+		t.Fatalf("WriteRaw: %v", err)
+	}
+
+	var got []string
+	for _, record := range decodeLines(t, out.String()) {
+		if record["application"] != "a" || record["level"] != WARN {
+			t.Errorf("wrote %v", record)
+		}
+		got = append(got, record["message"].(string))
+	}
+	if strings.Join(got, ",") != "first,second" {
+		t.Errorf("wrote messages %q, want first and second", got)
+	}
+}
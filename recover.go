@@ -0,0 +1,42 @@
+package go_vector_logger
+
+import "fmt"
+
+// Recover recovers a panic of the calling goroutine and logs it at the error
+// level as "panic: <value>", with the stack of the panic, then flushes the
+// logger. A logger set to the fatal level logs it at that level instead,
+// without exiting, so that no panic goes unrecorded. With rethrow, it panics again with the same value once the message
+// is written; otherwise the goroutine carries on after the deferred call. It
+// must be deferred directly:
+//
+//	defer logger.Recover(true)
+func (l *VectorLogger) Recover(rethrow bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	level := ERROR
+	if !l.shouldLog(level) {
+		level = FATAL
+	}
+	l.sendPanic(r, level)
+	_ = l.Flush()
+	if rethrow {
+		panic(r)
+	}
+}
+
+// sendPanic sends the message of a recovered panic at level, like
+// sendMessage.
+func (l *VectorLogger) sendPanic(r interface{}, level string) {
+	if !l.hasDestination() || !l.admit(level) {
+		return
+	}
+	msg := l.newMessage(fmt.Sprintf("panic: %v", r), level)
+	msg.Stacktrace = stackTrace()
+	if l.dedup(msg) {
+		return
+	}
+	l.send(msg)
+}
@@ -0,0 +1,66 @@
+package go_vector_logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecover(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{
+		BatchSize:     10,
+		BatchInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	func() {
+		defer logger.Recover(false)
+		panic("swallowed")
+	}()
+
+	var rethrown interface{}
+	func() {
+		defer func() { rethrown = recover() }()
+		defer logger.Recover(true)
+		panic("rethrown")
+	}()
+	if rethrown != "rethrown" {
+		t.Errorf("recovered %v after Recover(true), want the original value", rethrown)
+	}
+
+	// Both messages were flushed out of the batch before Recover returned.
+	lines := server.waitForLines(t, 2)
+	for i, want := range []string{"panic: swallowed", "panic: rethrown"} {
+		if i >= len(lines) {
+			t.Fatalf("received %q, want 2 lines", lines)
+		}
+		msg := decodeLines(t, lines[i])[0]
+		if msg["level"] != ERROR || msg["message"] != want {
+			t.Errorf("line %d = %v, want %q at ERROR", i, msg, want)
+		}
+		if trace, _ := msg["stacktrace"].(string); !strings.Contains(trace, "panic") {
+			t.Errorf("line %d has stack trace %q, want the stack of the panic", i, trace)
+		}
+	}
+}
+
+func TestRecoverAtFatalLevel(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", FATAL, "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	func() {
+		defer logger.Recover(false)
+		panic("filtered at ERROR")
+	}()
+
+	records := decodeLines(t, out.String())
+	if len(records) != 1 || records[0]["level"] != FATAL || records[0]["message"] != "panic: filtered at ERROR" {
+		t.Errorf("records = %v, want the panic at FATAL", records)
+	}
+}
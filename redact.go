@@ -0,0 +1,136 @@
+package go_vector_logger
+
+import "strings"
+
+// redactedValue replaces redacted field values and message substrings.
+const redactedValue = "[REDACTED]"
+
+// redactKeySet returns the keys of Options.RedactKeys in lower case.
+func redactKeySet(keys []string) map[string]bool {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[strings.ToLower(key)] = true
+	}
+	return set
+}
+
+// redacting reports whether messages go through redact.
+func (l *VectorLogger) redacting() bool {
+	return len(l.Options.RedactKeys) > 0 || l.Options.RedactKeyPattern != nil || len(l.Options.RedactPatterns) > 0
+}
+
+// redactKeySet returns the set built by New from Options.RedactKeys, or
+// builds it for a logger not created by New.
+func (l *VectorLogger) redactKeySet() map[string]bool {
+	if l.core != nil {
+		return l.redactKeys
+	}
+	return redactKeySet(l.Options.RedactKeys)
+}
+
+// redactsKey reports whether the value under key is redacted, keys being
+// the lower-cased Options.RedactKeys.
+func (l *VectorLogger) redactsKey(keys map[string]bool, key string) bool {
+	return keys[strings.ToLower(key)] || (l.Options.RedactKeyPattern != nil && l.Options.RedactKeyPattern.MatchString(key))
+}
+
+// redact scrubs msg as configured by Options.RedactKeys, RedactKeyPattern and
+// RedactPatterns. The maps of msg are copied before any change, as they are
+// shared with the logger and the caller.
+func (l *VectorLogger) redact(msg *Message) {
+	for _, pattern := range l.Options.RedactPatterns {
+		msg.Message = pattern.ReplaceAllString(msg.Message, redactedValue)
+	}
+	keys := l.redactKeySet()
+	if len(keys) == 0 && l.Options.RedactKeyPattern == nil {
+		return
+	}
+	if fields, changed := l.redactMap(keys, msg.Fields); changed {
+		msg.Fields = fields
+	}
+	for key := range msg.Tags {
+		if l.redactsKey(keys, key) {
+			tags := make(map[string]string, len(msg.Tags))
+			for k, v := range msg.Tags {
+				if l.redactsKey(keys, k) {
+					v = redactedValue
+				}
+				tags[k] = v
+			}
+			msg.Tags = tags
+			break
+		}
+	}
+}
+
+// redactMap returns a copy of fields with the values under redacted keys
+// replaced, recursively through nested maps and slices, and whether anything
+// was redacted; fields itself is returned unchanged otherwise.
+func (l *VectorLogger) redactMap(keys map[string]bool, fields map[string]interface{}) (map[string]interface{}, bool) {
+	var redacted map[string]interface{}
+	for k, v := range fields {
+		var value interface{} = redactedValue
+		changed := true
+		if !l.redactsKey(keys, k) {
+			value, changed = l.redactValue(keys, v)
+		}
+		if !changed {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(map[string]interface{}, len(fields))
+			for key, v := range fields {
+				redacted[key] = v
+			}
+		}
+		redacted[k] = value
+	}
+	if redacted == nil {
+		return fields, false
+	}
+	return redacted, true
+}
+
+// redactValue redacts the maps nested in v, see redactMap.
+func (l *VectorLogger) redactValue(keys map[string]bool, v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return l.redactMap(keys, v)
+	case []interface{}:
+		var redacted []interface{}
+		for i, item := range v {
+			value, changed := l.redactValue(keys, item)
+			if !changed {
+				continue
+			}
+			if redacted == nil {
+				redacted = append([]interface{}(nil), v...)
+			}
+			redacted[i] = value
+		}
+		if redacted == nil {
+			return v, false
+		}
+		return redacted, true
+	case []map[string]interface{}:
+		var redacted []map[string]interface{}
+		for i, item := range v {
+			value, changed := l.redactMap(keys, item)
+			if !changed {
+				continue
+			}
+			if redacted == nil {
+				redacted = append([]map[string]interface{}(nil), v...)
+			}
+			redacted[i] = value
+		}
+		if redacted == nil {
+			return v, false
+		}
+		return redacted, true
+	}
+	return v, false
+}
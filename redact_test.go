@@ -0,0 +1,103 @@
+package go_vector_logger
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     Options
+		message     string
+		fields      map[string]interface{}
+		wantMessage string
+		wantFields  map[string]interface{}
+	}{
+		{
+			name:        "keys",
+			options:     Options{RedactKeys: []string{"Password", "token"}},
+			message:     "login",
+			fields:      map[string]interface{}{"user": "bob", "PASSWORD": "hunter2", "token": 42},
+			wantMessage: "login",
+			wantFields:  map[string]interface{}{"user": "bob", "PASSWORD": redactedValue, "token": redactedValue},
+		},
+		{
+			name:        "nested keys",
+			options:     Options{RedactKeys: []string{"secret"}},
+			message:     "nested",
+			fields:      map[string]interface{}{"db": map[string]interface{}{"host": "db1", "secret": "s"}, "list": []interface{}{map[string]interface{}{"secret": "t"}}},
+			wantMessage: "nested",
+			wantFields:  map[string]interface{}{"db": map[string]interface{}{"host": "db1", "secret": redactedValue}, "list": []interface{}{map[string]interface{}{"secret": redactedValue}}},
+		},
+		{
+			name:        "nested keys flattened",
+			options:     Options{RedactKeys: []string{"secret"}, FlattenFields: true},
+			message:     "flat",
+			fields:      map[string]interface{}{"db": map[string]interface{}{"host": "db1", "secret": "s"}},
+			wantMessage: "flat",
+			wantFields:  map[string]interface{}{"db.host": "db1", "db.secret": redactedValue},
+		},
+		{
+			name:        "key pattern",
+			options:     Options{RedactKeyPattern: regexp.MustCompile(`(?i)_key$`)},
+			message:     "keys",
+			fields:      map[string]interface{}{"api_key": "abc", "keyboard": "qwerty"},
+			wantMessage: "keys",
+			wantFields:  map[string]interface{}{"api_key": redactedValue, "keyboard": "qwerty"},
+		},
+		{
+			name:        "message patterns",
+			options:     Options{RedactPatterns: []*regexp.Regexp{regexp.MustCompile(`Bearer \S+`), regexp.MustCompile(`\d{4}-\d{4}`)}},
+			message:     "auth Bearer abc.def for card 1234-5678",
+			fields:      map[string]interface{}{"Bearer": "kept"},
+			wantMessage: "auth [REDACTED] for card [REDACTED]",
+			wantFields:  map[string]interface{}{"Bearer": "kept"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &syncBuffer{}
+			tt.options.Writer = out
+			logger, err := New("test", "INFO", "", 0, tt.options)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			before := fmt.Sprint(tt.fields)
+			logger.InfoFields(tt.message, tt.fields)
+
+			record := decodeLines(t, out.String())[0]
+			if record["message"] != tt.wantMessage {
+				t.Errorf("message = %q, want %q", record["message"], tt.wantMessage)
+			}
+			if !reflect.DeepEqual(record["fields"], tt.wantFields) {
+				t.Errorf("fields = %v, want %v", record["fields"], tt.wantFields)
+			}
+			if after := fmt.Sprint(tt.fields); after != before {
+				t.Errorf("redaction changed the fields of the caller to %s", after)
+			}
+		})
+	}
+}
+
+func TestRedactionWithoutNew(t *testing.T) {
+	out := &syncBuffer{}
+	logger := &VectorLogger{Application: "a", Level: "INFO", Options: Options{
+		Writer:           out,
+		RedactKeys:       []string{"Password"},
+		RedactKeyPattern: regexp.MustCompile(`_key$`),
+		RedactPatterns:   []*regexp.Regexp{regexp.MustCompile(`Bearer \S+`)},
+	}}
+	logger.InfoFields("auth Bearer abc", map[string]interface{}{"password": "hunter2", "api_key": "abc", "user": "bob"})
+
+	record := decodeLines(t, out.String())[0]
+	if record["message"] != "auth [REDACTED]" {
+		t.Errorf("message = %q, want it redacted", record["message"])
+	}
+	want := map[string]interface{}{"password": redactedValue, "api_key": redactedValue, "user": "bob"}
+	if !reflect.DeepEqual(record["fields"], want) {
+		t.Errorf("fields = %v, want %v", record["fields"], want)
+	}
+}
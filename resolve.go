@@ -0,0 +1,44 @@
+package go_vector_logger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// resolve returns the addresses of the Vector host, see Options.Resolver.
+func (l *VectorLogger) resolve() ([]string, error) {
+	if l.Options.Resolver != nil {
+		return l.Options.Resolver(l.VectorHost)
+	}
+	return net.DefaultResolver.LookupHost(context.Background(), l.VectorHost)
+}
+
+// dialResolved resolves the Vector host and dials its addresses in turn,
+// starting after the one used by the previous connection, until one
+// accepts. The caller must hold l.mu.
+func (l *VectorLogger) dialResolved() (net.Conn, error) {
+	addrs, err := l.resolve()
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve vector host %q: %w", l.VectorHost, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("cannot resolve vector host %q: no addresses", l.VectorHost)
+	}
+
+	port := strconv.FormatInt(l.VectorPort, 10)
+	failures := make([]string, 0, len(addrs))
+	for i := range addrs {
+		index := (l.nextAddr + i) % len(addrs)
+		conn, errDial := l.dialer().Dial(l.network(), net.JoinHostPort(addrs[index], port))
+		if errDial == nil {
+			l.nextAddr = index + 1
+			return conn, nil
+		}
+		failures = append(failures, errDial.Error())
+	}
+	l.nextAddr++
+	return nil, fmt.Errorf("cannot connect to any address of vector host %q (%s)", l.VectorHost, strings.Join(failures, "; "))
+}
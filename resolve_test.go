@@ -0,0 +1,56 @@
+package go_vector_logger
+
+import (
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestResolveEveryReconnectRotates(t *testing.T) {
+	first := newTestServer(t)
+	port := first.port()
+	second, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", strconv.FormatInt(port, 10)))
+	if err != nil {
+		t.Skipf("127.0.0.2 unavailable: %v", err)
+	}
+	_ = second.Close()
+	servers := map[string]*testServer{
+		"127.0.0.1": first,
+		"127.0.0.2": newTestServerAt(t, net.JoinHostPort("127.0.0.2", strconv.FormatInt(port, 10))),
+	}
+
+	var lookups atomic.Int64
+	logger, err := New("test", "INFO", "vector.internal", port, Options{
+		ResolveEveryReconnect: true,
+		Resolver: func(host string) ([]string, error) {
+			lookups.Add(1)
+			if host != "vector.internal" {
+				t.Errorf("resolved %q", host)
+			}
+			return []string{"127.0.0.1", "127.0.0.2"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 4; i++ {
+		logger.Infof("message %d", i)
+		if err := logger.Reconnect(); err != nil {
+			t.Fatalf("Reconnect: %v", err)
+		}
+	}
+	for addr, server := range servers {
+		if got := server.conns.Load(); got < 2 {
+			t.Errorf("%s accepted %d connections, want the rotation to reach it at least twice", addr, got)
+		}
+		if lines := server.waitForLines(t, 2); len(lines) != 2 {
+			t.Errorf("%s received %q, want 2 messages", addr, lines)
+		}
+	}
+	if got := lookups.Load(); got < 5 {
+		t.Errorf("resolved the host %d times, want once per connection", got)
+	}
+}
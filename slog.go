@@ -0,0 +1,176 @@
+//go:build go1.21
+
+package go_vector_logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// slogHandler is the slog.Handler returned by VectorLogger.SlogHandler.
+type slogHandler struct {
+	logger *VectorLogger
+	attrs  map[string]interface{} // Attributes added by WithAttrs, nested by group.
+	groups []string               // Groups opened by WithGroup, outermost first.
+}
+
+// SlogHandler returns a slog.Handler sending records through l, so that l can
+// back a slog.Logger:
+//
+//	slog.New(logger.SlogHandler())
+//
+// slog levels below Debug map to TRACE, below Info to DEBUG, below Warn to
+// INFO, below Error to WARN and the rest to ERROR. Attributes become fields
+// of the message, groups nested objects. With Options.IncludeCaller the
+// caller is the one recorded by slog.
+func (l *VectorLogger) SlogHandler() slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+// Enabled implements slog.Handler.
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.shouldLog(slogLevel(level))
+}
+
+// Handle implements slog.Handler.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	level := slogLevel(r.Level)
+	if !h.logger.shouldLog(level) {
+		return nil
+	}
+
+	fields := copySlogAttrs(h.attrs)
+	if r.NumAttrs() > 0 {
+		group := slogGroup(fields, h.groups)
+		r.Attrs(func(a slog.Attr) bool {
+			addSlogAttr(group, a)
+			return true
+		})
+	}
+
+	l := h.logger.withFields(fields)
+	if !l.hasDestination() || !l.admit(level) {
+		return nil
+	}
+	msg := l.newMessage(r.Message, level)
+	if ctx != nil && l.Options.TraceIDFromContext != nil {
+		msg.TraceID, msg.SpanID = l.Options.TraceIDFromContext(ctx)
+	}
+	if l.Options.IncludeCaller && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		msg.File, msg.Line, msg.Function = frame.File, frame.Line, frame.Function
+	}
+	if l.dedup(msg) {
+		return nil
+	}
+	l.send(msg)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	child := &slogHandler{logger: h.logger, attrs: copySlogAttrs(h.attrs), groups: h.groups}
+	group := slogGroup(child.attrs, child.groups)
+	for _, a := range attrs {
+		addSlogAttr(group, a)
+	}
+	return child
+}
+
+// WithGroup implements slog.Handler.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &slogHandler{logger: h.logger, attrs: h.attrs, groups: append(groups, name)}
+}
+
+// slogLevel maps a slog level to the closest level of this package; levels
+// below slog.LevelDebug, e.g. a custom trace level, map to TRACE.
+func slogLevel(level slog.Level) string {
+	switch {
+	case level < slog.LevelDebug:
+		return TRACE
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	default:
+		return ERROR
+	}
+}
+
+// slogGroup returns the map of fields holding the attributes of the innermost
+// of groups, creating the intermediate maps as needed.
+func slogGroup(fields map[string]interface{}, groups []string) map[string]interface{} {
+	for _, name := range groups {
+		sub, ok := fields[name].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			fields[name] = sub
+		}
+		fields = sub
+	}
+	return fields
+}
+
+// addSlogAttr stores a in fields, following the slog conventions: empty
+// attributes and groups are ignored and the attributes of a group without a
+// key are inlined.
+func addSlogAttr(fields map[string]interface{}, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		fields[a.Key] = slogValue(a.Value)
+		return
+	}
+
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return
+	}
+	if a.Key != "" {
+		fields = slogGroup(fields, []string{a.Key})
+	}
+	for _, attr := range attrs {
+		addSlogAttr(fields, attr)
+	}
+}
+
+// slogValue converts a resolved, non-group slog value to a field value.
+// Durations and errors are rendered as text, which JSON would otherwise turn
+// into nanoseconds and an empty object.
+func slogValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+	}
+	return v.Any()
+}
+
+// copySlogAttrs returns a deep copy of the nested attribute maps built by
+// addSlogAttr, so that handlers never share a mutable map.
+func copySlogAttrs(attrs map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		if sub, ok := v.(map[string]interface{}); ok {
+			v = copySlogAttrs(sub)
+		}
+		copied[k] = v
+	}
+	return copied
+}
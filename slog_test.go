@@ -0,0 +1,88 @@
+//go:build go1.21
+
+package go_vector_logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSlogLevel(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug - 4, TRACE},
+		{slog.LevelDebug - 1, TRACE},
+		{slog.LevelDebug, DEBUG},
+		{slog.LevelInfo, INFO},
+		{slog.LevelInfo + 2, INFO},
+		{slog.LevelWarn, WARN},
+		{slog.LevelError, ERROR},
+		{slog.LevelError + 4, ERROR},
+	}
+	for _, tt := range tests {
+		if got := slogLevel(tt.level); got != tt.want {
+			t.Errorf("slogLevel(%v) = %s, want %s", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestSlogHandlerTrace(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New("test", "DEBUG", "", 0, Options{Writer: &out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	slogger := slog.New(logger.SlogHandler())
+	slogger.Log(context.Background(), slog.LevelDebug-4, "trace message")
+	if out.Len() != 0 {
+		t.Fatalf("trace record logged at DEBUG: %q", out.String())
+	}
+
+	if err := logger.SetLevel(TRACE); err != nil {
+		t.Fatalf("SetLevel: %v", err)
+	}
+	slogger.Log(context.Background(), slog.LevelDebug-4, "trace message")
+	if !strings.Contains(out.String(), `"level":"TRACE","message":"trace message"`) {
+		t.Errorf("got %q", out.String())
+	}
+}
+
+func TestSlogHandlerFieldsReachVector(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	slogger := slog.New(logger.SlogHandler()).With("service", "api").WithGroup("http")
+	slogger.Debug("filtered")
+	slogger.Warn("slow request", "status", 200, slog.Group("timing", slog.Int("ms", 1500)))
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := server.waitForLines(t, 1)
+	if len(lines) != 1 {
+		t.Fatalf("received %d lines, want 1", len(lines))
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(lines[0]), &msg); err != nil {
+		t.Fatalf("cannot decode %q: %v", lines[0], err)
+	}
+	want := map[string]interface{}{
+		"service": "api",
+		"http": map[string]interface{}{
+			"status": 200.0,
+			"timing": map[string]interface{}{"ms": 1500.0},
+		},
+	}
+	if msg.Level != WARN || msg.Message != "slow request" || !reflect.DeepEqual(msg.Fields, want) {
+		t.Errorf("received %+v, want fields %v", msg, want)
+	}
+}
@@ -0,0 +1,44 @@
+package go_vector_logger
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// packageFramePrefix starts the function name of every frame of this package
+// in a runtime.Stack trace.
+var packageFramePrefix = reflect.TypeOf(VectorLogger{}).PkgPath() + "."
+
+// wantsStackTrace reports whether messages at level carry a stack trace.
+func (l *VectorLogger) wantsStackTrace(level string) bool {
+	threshold, ok := levelSeverity[l.Options.StackTraceLevel]
+	if !ok {
+		return false
+	}
+	severity, ok := levelSeverity[level]
+	return ok && severity >= threshold
+}
+
+// stackTrace returns the stack of the calling goroutine as formatted by
+// runtime.Stack, without the frames of this package above the log call.
+func stackTrace() string {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	// A trace is a "goroutine N [running]:" header followed by two lines per
+	// frame: the function, then its file and line.
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	first := 1
+	for first+1 < len(lines) && strings.HasPrefix(lines[first], packageFramePrefix) {
+		first += 2
+	}
+	return lines[0] + "\n" + strings.Join(lines[first:], "\n")
+}
@@ -0,0 +1,104 @@
+package go_vector_logger
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the delivery counters of a logger.
+type Stats struct {
+	Sent       uint64 // Messages written to Vector or to Options.Writer.
+	Failed     uint64 // Messages whose delivery failed.
+	Reconnects uint64 // Connections re-established after a lost one; idle closes and NoPersistentConnection dials do not count.
+	Dropped    uint64 // Messages lost: full queues or buffers, or failures not absorbed by the FailureHandler.
+
+	VerifyAttempts uint64 // Connection attempts made by the last VerifyConnectivity call.
+}
+
+// counters holds the delivery counters behind Stats.
+type counters struct {
+	sent       atomic.Uint64
+	failed     atomic.Uint64
+	reconnects atomic.Uint64
+	dropped    atomic.Uint64
+
+	verifyAttempts atomic.Uint64
+}
+
+// Stats returns the delivery counters of the logger. Loggers derived with
+// With share the counters of their root.
+func (l *VectorLogger) Stats() Stats {
+	if l.core == nil {
+		return Stats{}
+	}
+	return Stats{
+		Sent:       l.stats.sent.Load(),
+		Failed:     l.stats.failed.Load(),
+		Reconnects: l.stats.reconnects.Load(),
+		Dropped:    l.stats.dropped.Load(),
+
+		VerifyAttempts: l.stats.verifyAttempts.Load(),
+	}
+}
+
+// LastError returns the most recent delivery error and when it happened, or a
+// nil error once a later message has been delivered successfully. Together
+// with IsConnected it lets a health check report degraded log shipping.
+func (l *VectorLogger) LastError() (error, time.Time) {
+	if l.core == nil {
+		return nil, time.Time{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastErr, l.lastErrTime
+}
+
+// setLastError records a delivery error, or clears it when err is nil. The
+// caller must hold l.mu.
+func (l *VectorLogger) setLastError(err error) {
+	l.lastErr = err
+	if err == nil {
+		l.lastErrTime = time.Time{}
+		return
+	}
+	l.lastErrTime = time.Now()
+}
+
+// MetricsRegistry receives the metrics of a logger, see RegisterMetrics. Each
+// metric is registered with a function returning its current value, which
+// maps directly onto the CounterFunc and GaugeFunc of Prometheus, e.g.
+//
+//	func (r promRegistry) CounterFunc(name, help string, value func() float64) {
+//		r.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, value))
+//	}
+type MetricsRegistry interface {
+	CounterFunc(name, help string, value func() float64)
+	GaugeFunc(name, help string, value func() float64)
+}
+
+// RegisterMetrics registers the delivery counters of the logger, the ones of
+// Stats, whether it is connected to Vector and the length of its async queue
+// with r. The values are read when r asks for them, without taking the
+// logger's lock.
+func (l *VectorLogger) RegisterMetrics(r MetricsRegistry) {
+	if l.core == nil {
+		return
+	}
+	counter := func(c *atomic.Uint64) func() float64 {
+		return func() float64 { return float64(c.Load()) }
+	}
+	r.CounterFunc("vector_logger_messages_sent_total", "Messages written to Vector or to the configured writer.", counter(&l.stats.sent))
+	r.CounterFunc("vector_logger_messages_failed_total", "Messages whose delivery failed.", counter(&l.stats.failed))
+	r.CounterFunc("vector_logger_messages_dropped_total", "Messages lost because of full queues or buffers, or failed deliveries.", counter(&l.stats.dropped))
+	r.CounterFunc("vector_logger_reconnects_total", "Connections to Vector re-established after a lost one.", counter(&l.stats.reconnects))
+	r.GaugeFunc("vector_logger_connected", "Whether the logger holds a connection to Vector (1) or not (0).", func() float64 {
+		if l.liveConn.Load() != nil {
+			return 1
+		}
+		return 0
+	})
+	r.GaugeFunc("vector_logger_queue_length", "Messages waiting in the async queue.", func() float64 {
+		return float64(l.QueueLen())
+	})
+}
@@ -0,0 +1,145 @@
+package go_vector_logger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsCountsReconnectsAfterLostConnections(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{InternalErrorWriter: &syncBuffer{}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("before")
+	server.waitForLines(t, 1)
+	server.dropConnections()
+
+	// Writes on the dropped connection fail once the peer's reset arrives.
+	deadline := time.Now().Add(5 * time.Second)
+	for server.conns.Load() < 2 && time.Now().Before(deadline) {
+		logger.Info("after")
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := server.conns.Load(); got != 2 {
+		t.Fatalf("got %d connections, want 2", got)
+	}
+	if got := logger.Stats().Reconnects; got != 1 {
+		t.Errorf("Reconnects = %d, want 1", got)
+	}
+
+	server.dropConnections()
+	if err := logger.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if got := logger.Stats().Reconnects; got != 2 {
+		t.Errorf("Reconnects = %d after Ping, want 2", got)
+	}
+}
+
+func TestStatsIgnoresDialsThatAreNotReconnects(t *testing.T) {
+	server := newTestServer(t)
+	perMessage, err := New("test", "INFO", "127.0.0.1", server.port(), Options{NoPersistentConnection: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		perMessage.Info("message")
+	}
+	if got := perMessage.Stats().Reconnects; got != 0 {
+		t.Errorf("Reconnects = %d with NoPersistentConnection, want 0", got)
+	}
+	_ = perMessage.Close()
+
+	closed, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	closed.Info("before")
+	if err := closed.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	closed.Info("after one")
+	closed.Info("after two")
+	if got := closed.Stats().Reconnects; got != 0 {
+		t.Errorf("Reconnects = %d after Close, want 0", got)
+	}
+
+	if lines := server.waitForLines(t, 6); len(lines) != 6 {
+		t.Errorf("received %d lines, want 6", len(lines))
+	}
+}
+
+func TestLastErrorClearedBySuccess(t *testing.T) {
+	port := closedPort(t)
+	logger, err := New("test", "INFO", "127.0.0.1", port, Options{LazyConnect: true, InternalErrorWriter: &syncBuffer{}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	if err, at := logger.LastError(); err != nil || !at.IsZero() {
+		t.Errorf("LastError = %v, %v before any message", err, at)
+	}
+
+	before := time.Now()
+	logger.Info("refused")
+	err, at := logger.LastError()
+	if err == nil || at.Before(before) {
+		t.Errorf("LastError = %v, %v after a failed send", err, at)
+	}
+
+	server := newTestServerOn(t, port)
+	logger.Info("delivered")
+	server.waitForLines(t, 1)
+	if err, _ := logger.LastError(); err != nil {
+		t.Errorf("LastError = %v after a successful send", err)
+	}
+}
+
+// fakeRegistry is a MetricsRegistry keeping the registered value functions by
+// name, as a Prometheus adapter would register CounterFunc and GaugeFunc.
+type fakeRegistry map[string]func() float64
+
+func (r fakeRegistry) CounterFunc(name, help string, value func() float64) { r[name] = value }
+
+func (r fakeRegistry) GaugeFunc(name, help string, value func() float64) { r[name] = value }
+
+// value returns the current value of the metric name.
+func (r fakeRegistry) value(t *testing.T, name string) float64 {
+	t.Helper()
+	value, ok := r[name]
+	if !ok {
+		t.Fatalf("metric %s not registered", name)
+	}
+	return value()
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port(), Options{LazyConnect: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	registry := fakeRegistry{}
+	logger.RegisterMetrics(registry)
+
+	if got := registry.value(t, "vector_logger_connected"); got != 0 {
+		t.Errorf("connected = %v before the first message", got)
+	}
+	logger.Info("one")
+	logger.Info("two")
+	if got := registry.value(t, "vector_logger_messages_sent_total"); got != 2 {
+		t.Errorf("sent = %v, want 2", got)
+	}
+	if got := registry.value(t, "vector_logger_connected"); got != 1 {
+		t.Errorf("connected = %v after sending", got)
+	}
+	for _, name := range []string{"vector_logger_messages_failed_total", "vector_logger_messages_dropped_total", "vector_logger_reconnects_total"} {
+		if got := registry.value(t, name); got != 0 {
+			t.Errorf("%s = %v, want 0", name, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSyslogFacility = 1                                  // The "user-level messages" facility.
+	syslogTimestampFormat = "2006-01-02T15:04:05.000000Z07:00" // RFC 3339 with the microseconds RFC 5424 allows at most.
+)
+
+// syslogSeverity maps the levels to RFC 5424 severities.
+var syslogSeverity = map[string]int{
+	TRACE: 7, // Debug
+	DEBUG: 7, // Debug
+	INFO:  6, // Informational
+	WARN:  4, // Warning
+	ERROR: 3, // Error
+	FATAL: 2, // Critical
+}
+
+// SyslogEncoder encodes messages as RFC 5424 syslog lines:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MESSAGE
+type SyslogEncoder struct {
+	Facility     int  // Syslog facility (0-23) used to compute PRI.
+	KeepNewlines bool // Write control characters of the message unescaped, see RawEncoder.
+}
+
+// Encode implements Encoder.
+func (e SyslogEncoder) Encode(buf *bytes.Buffer, msg *Message) error {
+	severity, ok := syslogSeverity[msg.level]
+	if !ok {
+		severity = syslogSeverity[INFO]
+	}
+
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(e.Facility*8 + severity))
+	buf.WriteString(">1 ")
+	if msg.time.IsZero() {
+		writeSyslogHeaderField(buf, msg.Timestamp, 255)
+	} else {
+		// RFC 5424 requires an RFC 3339 timestamp whatever the format of the
+		// timestamp field.
+		buf.WriteString(msg.time.Format(syslogTimestampFormat))
+	}
+	buf.WriteByte(' ')
+	writeSyslogHeaderField(buf, msg.Hostname, 255)
+	buf.WriteByte(' ')
+	writeSyslogHeaderField(buf, msg.Application, 48)
+	buf.WriteByte(' ')
+	if msg.PID != 0 {
+		buf.WriteString(strconv.Itoa(msg.PID))
+	} else {
+		buf.WriteByte('-')
+	}
+	buf.WriteString(" - - ")
+	writeMessageText(buf, msg.Message, e.KeepNewlines)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// writeSyslogHeaderField writes a header field, keeping only printable ASCII
+// without spaces as RFC 5424 requires and truncating it to maxLen. An empty
+// value is written as the NILVALUE "-".
+func writeSyslogHeaderField(buf *bytes.Buffer, value string, maxLen int) {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	if value == "" {
+		value = "-"
+	}
+	buf.WriteString(value)
+}
@@ -0,0 +1,78 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogTimestampIsRFC3339(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC) }
+	const want = "<14>1 2024-05-06T07:08:09.123456Z "
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"default", Options{}},
+		{"epoch timestamps", Options{EpochTimestamps: true}},
+		{"custom format", Options{TimestampFormat: "02/01/2006 15:04"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := tt.options
+			opts.Writer = &out
+			opts.Format = FormatSyslog
+			opts.Clock = clock
+			logger, err := New("app", "INFO", "", 0, opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			logger.Info("hello")
+			if got := out.String(); !strings.HasPrefix(got, want) {
+				t.Errorf("got %q, want prefix %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSyslogFramingAndSeverity(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC) }
+	facility := 16 // local0
+	var out bytes.Buffer
+	logger, err := New("my app", "TRACE", "", 0, Options{
+		Writer:         &out,
+		Format:         FormatSyslog,
+		SyslogFacility: &facility,
+		Clock:          clock,
+		IncludePID:     true,
+		NoExit:         true,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Trace("trace")
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("two\nlines")
+	logger.Fatal("fatal")
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []struct {
+		pri     int
+		message string
+	}{{135, "trace"}, {135, "debug"}, {134, "info"}, {132, "warn"}, {131, `two\nlines`}, {130, "fatal"}}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d frames, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, w := range want {
+		frame := fmt.Sprintf("<%d>1 2024-05-06T07:08:09.000000Z - my_app %d - - %s", w.pri, os.Getpid(), w.message)
+		if lines[i] != frame {
+			t.Errorf("frame %d = %q, want %q", i, lines[i], frame)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package vectorloggertest helps testing code that logs through a
+// go_vector_logger.VectorLogger: New returns a logger recording every message
+// in memory, so that tests can assert what was logged.
+package vectorloggertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	vectorlogger "github.com/scor2k/go-vector-logger"
+)
+
+// Sink is an io.Writer decoding the JSON lines written by a logger into
+// messages. It is safe for concurrent use.
+type Sink struct {
+	mu       sync.Mutex
+	pending  []byte // Incomplete line from previous writes.
+	messages []vectorlogger.Message
+}
+
+// NewSink returns an empty sink, to be set as Options.Writer of a logger
+// using the JSON format.
+func NewSink() *Sink {
+	return &Sink{}
+}
+
+// New returns a logger at level recording its messages in the returned sink.
+// The sink replaces Options.Writer and the format is forced to JSON; the
+// other options are kept. In async mode, call Flush on the logger before
+// looking at the sink.
+func New(level string, options ...vectorlogger.Options) (*vectorlogger.VectorLogger, *Sink, error) {
+	var opts vectorlogger.Options
+	switch len(options) {
+	case 0:
+	case 1:
+		opts = options[0]
+	default:
+		return nil, nil, fmt.Errorf("Can only pass in one Options struct")
+	}
+
+	sink := NewSink()
+	opts.Writer = sink
+	opts.Format = vectorlogger.FormatJSON
+	opts.FieldNames = vectorlogger.FieldNames{}
+	opts.StreamHeader = false
+	opts.Framing = vectorlogger.FramingNewline
+	logger, err := vectorlogger.New("test", level, "", 0, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return logger, sink, nil
+}
+
+// Write implements io.Writer. Every complete line of p is decoded as one
+// message; an undecodable line is an error.
+func (s *Sink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, p...)
+	for {
+		end := bytes.IndexByte(s.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		line := s.pending[:end]
+		s.pending = s.pending[end+1:]
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		msg, err := decode(line)
+		if err != nil {
+			return len(p), err
+		}
+		s.messages = append(s.messages, msg)
+	}
+}
+
+// decode parses one JSON line, with the timestamp either formatted or in
+// Unix milliseconds (Options.EpochTimestamps).
+func decode(line []byte) (vectorlogger.Message, error) {
+	var record struct {
+		vectorlogger.Message
+		Timestamp json.RawMessage `json:"timestamp"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return vectorlogger.Message{}, fmt.Errorf("cannot decode log message %q: %w", line, err)
+	}
+	msg := record.Message
+	if err := json.Unmarshal(record.Timestamp, &msg.Timestamp); err != nil {
+		msg.Timestamp = string(record.Timestamp)
+	}
+	return msg, nil
+}
+
+// Messages returns a copy of the messages recorded so far, oldest first.
+func (s *Sink) Messages() []vectorlogger.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]vectorlogger.Message(nil), s.messages...)
+}
+
+// Contains reports whether a message at level (compared case-insensitively
+// with the level field) contains substr.
+func (s *Sink) Contains(level, substr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range s.messages {
+		if strings.EqualFold(msg.Level, level) && strings.Contains(msg.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset forgets the messages recorded so far.
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = nil
+	s.messages = nil
+}
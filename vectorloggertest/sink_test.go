@@ -0,0 +1,121 @@
+package vectorloggertest
+
+import (
+	"strings"
+	"testing"
+
+	vectorlogger "github.com/scor2k/go-vector-logger"
+)
+
+func TestNewRecordsMessages(t *testing.T) {
+	logger, sink, err := New("DEBUG")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("debug message")
+	logger.With(map[string]interface{}{"user": "alice"}).Warnf("warn %d", 42)
+
+	messages := sink.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("recorded %d messages, want 2", len(messages))
+	}
+	if messages[0].Level != vectorlogger.DEBUG || messages[0].Message != "debug message" || messages[0].Application != "test" {
+		t.Errorf("first message = %+v", messages[0])
+	}
+	if messages[1].Fields["user"] != "alice" {
+		t.Errorf("fields = %v, want user=alice", messages[1].Fields)
+	}
+	if !sink.Contains("warn", "warn 42") {
+		t.Error(`Contains("warn", "warn 42") = false`)
+	}
+	if sink.Contains("ERROR", "warn 42") {
+		t.Error(`Contains("ERROR", "warn 42") = true`)
+	}
+
+	sink.Reset()
+	if got := len(sink.Messages()); got != 0 {
+		t.Errorf("recorded %d messages after Reset", got)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	logger, sink, err := New("INFO", vectorlogger.Options{
+		Format:          vectorlogger.FormatLogfmt,
+		EpochTimestamps: true,
+		DefaultFields:   map[string]interface{}{"env": "test"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("hello")
+
+	messages := sink.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("recorded %d messages, want 1", len(messages))
+	}
+	if messages[0].Fields["env"] != "test" {
+		t.Errorf("fields = %v, want env=test", messages[0].Fields)
+	}
+	if messages[0].Timestamp == "" || strings.ContainsAny(messages[0].Timestamp, "-:") {
+		t.Errorf("timestamp = %q, want Unix milliseconds", messages[0].Timestamp)
+	}
+
+	if _, _, err := New("INFO", vectorlogger.Options{}, vectorlogger.Options{}); err == nil {
+		t.Error("New accepted two Options")
+	}
+	if _, _, err := New("LOUD"); err == nil {
+		t.Error("New accepted an unknown level")
+	}
+}
+
+func TestSinkDecodesPartialWrites(t *testing.T) {
+	sink := NewSink()
+	for _, chunk := range []string{
+		`{"timestamp":"2024-01-02T03:04:05.000Z","application":"app",`,
+		`"level":"INFO","message":"first"}` + "\n\n" + `{"timestamp":1704164645000,`,
+		`"application":"app","level":"ERROR","message":"second"}` + "\n",
+	} {
+		if n, err := sink.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+
+	messages := sink.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("decoded %d messages, want 2", len(messages))
+	}
+	if messages[0].Timestamp != "2024-01-02T03:04:05.000Z" || messages[0].Message != "first" {
+		t.Errorf("first message = %+v", messages[0])
+	}
+	if messages[1].Timestamp != "1704164645000" || messages[1].Level != "ERROR" {
+		t.Errorf("second message = %+v", messages[1])
+	}
+
+	if _, err := sink.Write([]byte("not json\n")); err == nil {
+		t.Error("Write accepted an undecodable line")
+	}
+}
+
+func TestSinkAsyncFlushAndClose(t *testing.T) {
+	logger, sink, err := New("INFO", vectorlogger.Options{Async: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Info("flushed")
+	if err := logger.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !sink.Contains("INFO", "flushed") {
+		t.Error("message not recorded after Flush")
+	}
+
+	for i := 0; i < 100; i++ {
+		logger.Infof("queued %d", i)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := len(sink.Messages()); got != 101 {
+		t.Errorf("recorded %d messages after Close, want 101", got)
+	}
+}
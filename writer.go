@@ -0,0 +1,155 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// writerOverride holds the destination set by SetWriter; a nil w means Vector.
+type writerOverride struct {
+	w io.Writer
+}
+
+// SetWriter redirects the messages of l, and of every logger sharing its
+// connection, to w instead of Vector, e.g. to a file during maintenance; a
+// nil w switches back to Vector, over a connection opened by the next
+// message. The pending batch is written out first, and the connection to
+// Vector is closed when switching to w. It overrides Options.Writer and is
+// safe to call while the logger is in use.
+func (l *VectorLogger) SetWriter(w io.Writer) {
+	if l.core == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+	if l.usesNetwork() {
+		_ = l.flushBatch()
+	}
+	l.output.Store(&writerOverride{w: w})
+	if w != nil {
+		l.closeConnection()
+		return
+	}
+	if l.usesNetwork() && !l.usesHTTP() && !l.Options.NoPersistentConnection {
+		l.startConnectionManager()
+	}
+}
+
+// writer returns the writer messages are sent to instead of Vector, if any.
+func (l *VectorLogger) writer() io.Writer {
+	if l.core != nil {
+		if override := l.core.output.Load(); override != nil {
+			return override.w
+		}
+	}
+	return l.Options.Writer
+}
+
+// levelWriter is the io.Writer returned by VectorLogger.Writer.
+type levelWriter struct {
+	logger *VectorLogger
+	level  string
+
+	mu   sync.Mutex
+	line []byte // Start of a line whose newline was not written yet.
+}
+
+// Writer returns an io.Writer logging every line written to it as one
+// message at level, without its newline, so that the standard library log
+// package, or any library accepting an io.Writer, can ship its output to
+// Vector:
+//
+//	log.New(logger.Writer(INFO), "", 0)
+//
+// A line may span several writes and a write may hold several lines; an
+// incomplete last line is kept until its newline is written, or logged as
+// is by Flush or Close, of the logger or of the writer, which implements
+// io.Closer. Write always consumes the whole of p and never fails; delivery
+// errors are handled like those of Info and friends. The writer is safe for
+// concurrent use. An unknown level is reported like internal errors and the
+// returned writer discards everything.
+func (l *VectorLogger) Writer(level string) io.Writer {
+	parsed, err := parseLevel(level, l.Options.LevelAliases)
+	if err != nil {
+		l.reportError(fmt.Errorf("cannot create a log writer: %w", err))
+		return io.Discard
+	}
+	return &levelWriter{logger: l, level: parsed}
+}
+
+// Write implements io.Writer.
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.line = append(w.line, p...)
+	var lines []string
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(string(w.line[:i]), "\r"))
+		w.line = w.line[i+1:]
+	}
+	if len(w.line) == 0 {
+		w.line = nil
+	}
+	w.track()
+	w.mu.Unlock()
+
+	for _, line := range lines {
+		if w.logger.shouldLog(w.level) {
+			w.logger.sendMessage(line, w.level)
+		}
+	}
+	return len(p), nil
+}
+
+// Close logs the incomplete last line, if any. The writer remains usable.
+func (w *levelWriter) Close() error {
+	w.mu.Lock()
+	line := strings.TrimSuffix(string(w.line), "\r")
+	w.line = nil
+	w.track()
+	w.mu.Unlock()
+
+	if line != "" && w.logger.shouldLog(w.level) {
+		w.logger.sendMessage(line, w.level)
+	}
+	return nil
+}
+
+// track registers w with its logger while it holds an incomplete line, so
+// that Flush and Close log it. The caller must hold w.mu.
+func (w *levelWriter) track() {
+	l := w.logger
+	if l.core == nil {
+		return
+	}
+	l.writersMu.Lock()
+	defer l.writersMu.Unlock()
+	if w.line == nil {
+		delete(l.writers, w)
+		return
+	}
+	if l.writers == nil {
+		l.writers = make(map[*levelWriter]struct{})
+	}
+	l.writers[w] = struct{}{}
+}
+
+// flushWriters logs the incomplete lines held by the writers of l, see
+// Writer.
+func (l *VectorLogger) flushWriters() {
+	l.writersMu.Lock()
+	writers := l.writers
+	l.writers = nil
+	l.writersMu.Unlock()
+
+	for w := range writers {
+		_ = w.Close()
+	}
+}
@@ -0,0 +1,117 @@
+package go_vector_logger
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriterWithStandardLogger(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	std := log.New(logger.Writer(WARN), "legacy: ", 0)
+	std.Print("first")
+	std.Printf("second %d", 2)
+	log.New(logger.Writer(DEBUG), "", 0).Print("filtered")
+
+	var got [][2]interface{}
+	for _, record := range decodeLines(t, out.String()) {
+		got = append(got, [2]interface{}{record["level"], record["message"]})
+	}
+	want := [][2]interface{}{{WARN, "legacy: first"}, {WARN, "legacy: second 2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
+
+func TestSetWriterSwitchesFromVector(t *testing.T) {
+	server := newTestServer(t)
+	logger, err := New("test", "INFO", "127.0.0.1", server.port())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer logger.Close()
+	logger.Info("to vector")
+	server.waitForLines(t, 1)
+
+	var buf bytes.Buffer
+	logger.SetWriter(&buf)
+	if logger.IsConnected() {
+		t.Error("connection to Vector kept open after SetWriter")
+	}
+	logger.Info("to buffer")
+	if got := server.received(); len(got) != 1 {
+		t.Errorf("server received %q after SetWriter", got)
+	}
+	if !strings.Contains(buf.String(), `"message":"to buffer"`) {
+		t.Errorf("buffer holds %q", buf.String())
+	}
+
+	logger.SetWriter(nil)
+	logger.Info("back to vector")
+	if lines := server.waitForLines(t, 2); countContaining(lines, "back to vector") != 1 {
+		t.Errorf("server received %q after switching back", lines)
+	}
+}
+
+func TestWriterSplitsLines(t *testing.T) {
+	out := &syncBuffer{}
+	logger, err := New("test", "INFO", "", 0, Options{Writer: out})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	w := logger.Writer(INFO)
+	for _, chunk := range []string{"split ", "across writes\nfirst of two\r\nsecond of two\n", "incomplete"} {
+		if n, err := w.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	messages := func() []interface{} {
+		var got []interface{}
+		for _, record := range decodeLines(t, out.String()) {
+			got = append(got, record["message"])
+		}
+		return got
+	}
+	want := []interface{}{"split across writes", "first of two", "second of two"}
+	if got := messages(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	want = append(want, "incomplete")
+	if got := messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages after Flush = %q, want %q", got, want)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages after Close = %q, want %q", got, want)
+	}
+}
+
+func TestWriterCloseLogsIncompleteLine(t *testing.T) {
+	out := &syncBuffer{}
+	logger := &VectorLogger{Application: "a", Level: "INFO", Options: Options{Writer: out}}
+	w := logger.Writer(WARN)
+	_, _ = io.WriteString(w, "no newline")
+	if out.String() != "" {
+		t.Fatalf("incomplete line logged before Close: %s", out.String())
+	}
+	if err := w.(io.Closer).Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	records := decodeLines(t, out.String())
+	if len(records) != 1 || records[0]["message"] != "no newline" || records[0]["level"] != WARN {
+		t.Errorf("records = %v, want the incomplete line at WARN", records)
+	}
+}